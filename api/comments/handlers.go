@@ -2,15 +2,24 @@ package comments
 
 import (
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/asdine/storm"
 	"github.com/dbeliakov/revisor/api/auth"
+	"github.com/dbeliakov/revisor/api/review"
 	"github.com/dbeliakov/revisor/api/store"
 	"github.com/dbeliakov/revisor/api/utils"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/xerrors"
 )
 
-// AddComment to line of review
+var (
+	// ErrNotAuthor error
+	ErrNotAuthor = xerrors.New("User is not author of comment")
+)
+
+// AddComment to line, range of lines or the whole review
 var AddComment = auth.Required(func(w http.ResponseWriter, r *http.Request) {
 	user, err := auth.UserFromRequest(r)
 	if err != nil {
@@ -23,23 +32,34 @@ var AddComment = auth.Required(func(w http.ResponseWriter, r *http.Request) {
 		Text     string `json:"text" validate:"required"`
 		Parent   *int   `json:"parent,omitempty"`
 		ReviewID int    `json:"review_id" validate:"required"`
-		LineID   string `json:"line_id" validate:"required"`
+		// Empty line is used for comment on the whole review
+		LineID    string `json:"line_id"`
+		EndLineID string `json:"end_line_id"`
 	}
 	if err := utils.UnmarshalForm(w, r, &form); err != nil {
 		return
 	}
+	if form.EndLineID == form.LineID {
+		form.EndLineID = ""
+	}
+	target, err := commentTarget(w, user.Login, form.ReviewID)
+	if err != nil {
+		return
+	}
 
 	comment := store.Comment{
-		Author:   user.Login,
-		Created:  time.Now().Unix(),
-		Text:     form.Text,
-		ParentID: 0,
-		LineID:   form.LineID,
+		Author:    user.Login,
+		Created:   time.Now().Unix(),
+		Text:      form.Text,
+		ParentID:  0,
+		LineID:    form.LineID,
+		EndLineID: form.EndLineID,
 	}
 
 	if form.Parent != nil {
-		comment.ParentID = *form.Parent
-		if exists, err := store.Comments.CheckExists(form.ReviewID, comment.ParentID); err != nil || !exists {
+		// Reply is attached to the same lines as parent comment
+		parent, err := store.Comments.FindCommentByID(form.ReviewID, *form.Parent)
+		if err != nil {
 			logrus.Warnf("Cannot find parent comment: %+v", err)
 			utils.Error(w, utils.JSONErrorResponse{
 				Status:        http.StatusBadRequest,
@@ -48,6 +68,11 @@ var AddComment = auth.Required(func(w http.ResponseWriter, r *http.Request) {
 			})
 			return
 		}
+		comment.ParentID = parent.ID
+		comment.LineID, comment.EndLineID = parent.LineID, parent.EndLineID
+	}
+	if err := commentLines(w, target, comment.LineID, comment.EndLineID); err != nil {
+		return
 	}
 	err = store.Comments.CreateComment(form.ReviewID, &comment)
 	if err != nil {
@@ -55,6 +80,275 @@ var AddComment = auth.Required(func(w http.ResponseWriter, r *http.Request) {
 		utils.Error(w, utils.InternalErrorResponse("Cannot save comment to database"))
 		return
 	}
+	err = store.Events.AddEvent(form.ReviewID, &store.Event{
+		Type:      store.EventCommentPosted,
+		Actor:     user.Login,
+		Created:   comment.Created,
+		CommentID: comment.ID,
+	})
+	if err != nil {
+		logrus.Errorf("Cannot save event for review %d: %+v", form.ReviewID, err)
+	}
+
+	utils.Ok(w, nil)
+})
+
+// commentForm identifies comment in request
+type commentForm struct {
+	ReviewID  int `json:"review_id" validate:"required"`
+	CommentID int `json:"comment_id" validate:"required"`
+}
+
+// authorComment loads comment, which can be changed by user. User must still have access to review.
+// If error occurs, writes error message to response writer
+func authorComment(w http.ResponseWriter, login string, form commentForm) (store.Comment, error) {
+	if _, err := commentTarget(w, login, form.ReviewID); err != nil {
+		return store.Comment{}, err
+	}
+	comment, err := store.Comments.FindCommentByID(form.ReviewID, form.CommentID)
+	if err == nil && comment.Deleted {
+		err = storm.ErrNotFound
+	}
+	if err != nil {
+		logrus.Warnf("Cannot find comment %d in review %d: %+v", form.CommentID, form.ReviewID, err)
+		utils.Error(w, utils.JSONErrorResponse{
+			Status:        http.StatusNotFound,
+			Message:       "No such comment",
+			ClientMessage: "Не удалось найти комментарий",
+		})
+		return comment, err
+	}
+	if comment.Author != login {
+		logrus.Warnf("User %s tries to change comment %d of user %s", login, comment.ID, comment.Author)
+		utils.Error(w, utils.JSONErrorResponse{
+			Status:        http.StatusForbidden,
+			Message:       "Only author can change comment",
+			ClientMessage: "Изменять комментарий может только его автор",
+		})
+		return comment, ErrNotAuthor
+	}
+	return comment, nil
+}
+
+// saveCommentEvent to timeline of review. Errors are only logged
+func saveCommentEvent(reviewID int, eventType store.EventType, comment store.Comment, actor string) {
+	err := store.Events.AddEvent(reviewID, &store.Event{
+		Type:      eventType,
+		Actor:     actor,
+		Created:   time.Now().Unix(),
+		CommentID: comment.ID,
+	})
+	if err != nil {
+		logrus.Errorf("Cannot save event for review %d: %+v", reviewID, err)
+	}
+}
+
+// EditComment changes text of comment. Previous text is kept in history of edits
+var EditComment = auth.Required(func(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.UserFromRequest(r)
+	if err != nil {
+		logrus.Errorf("Error while getting user from request context: %+v", err)
+		utils.Error(w, utils.InternalErrorResponse("No authorized user for this request"))
+		return
+	}
+
+	var form struct {
+		commentForm
+		Text string `json:"text" validate:"required"`
+	}
+	if err := utils.UnmarshalForm(w, r, &form); err != nil {
+		return
+	}
+	comment, err := authorComment(w, user.Login, form.commentForm)
+	if err != nil {
+		return
+	}
+	if comment.Text == form.Text {
+		utils.Ok(w, nil)
+		return
+	}
+
+	comment.Updated = time.Now().Unix()
+	comment.Edits = append(comment.Edits, store.CommentEdit{Text: comment.Text, Edited: comment.Updated})
+	comment.Text = form.Text
+	err = store.Comments.UpdateComment(form.ReviewID, &comment)
+	if err != nil {
+		logrus.Errorf("Cannot save edited comment: %+v", err)
+		utils.Error(w, utils.InternalErrorResponse("Cannot save comment to database"))
+		return
+	}
+	saveCommentEvent(form.ReviewID, store.EventCommentEdited, comment, user.Login)
+	utils.Ok(w, nil)
+})
+
+// DeleteComment removes comment. Comment with replies is replaced with tombstone, so thread is kept
+var DeleteComment = auth.Required(func(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.UserFromRequest(r)
+	if err != nil {
+		logrus.Errorf("Error while getting user from request context: %+v", err)
+		utils.Error(w, utils.InternalErrorResponse("No authorized user for this request"))
+		return
+	}
 
+	var form commentForm
+	if err := utils.UnmarshalForm(w, r, &form); err != nil {
+		return
+	}
+	comment, err := authorComment(w, user.Login, form)
+	if err != nil {
+		return
+	}
+
+	err = deleteComment(form.ReviewID, comment)
+	if err != nil {
+		logrus.Errorf("Cannot delete comment: %+v", err)
+		utils.Error(w, utils.InternalErrorResponse("Cannot delete comment"))
+		return
+	}
+	saveCommentEvent(form.ReviewID, store.EventCommentDeleted, comment, user.Login)
+	utils.Ok(w, nil)
+})
+
+// deleteComment or replace it with tombstone, if it has replies. Tombstones of parents,
+// which have no replies anymore, are removed too
+func deleteComment(reviewID int, comment store.Comment) error {
+	hasReplies, err := store.Comments.HasReplies(reviewID, comment.ID)
+	if err != nil {
+		return err
+	}
+	if hasReplies {
+		comment.Deleted = true
+		comment.Text = ""
+		comment.Edits = nil
+		comment.Updated = time.Now().Unix()
+		return store.Comments.UpdateComment(reviewID, &comment)
+	}
+	err = store.Comments.DeleteComment(reviewID, &comment)
+	if err != nil || comment.ParentID == 0 {
+		return err
+	}
+	parent, err := store.Comments.FindCommentByID(reviewID, comment.ParentID)
+	if err != nil || !parent.Deleted {
+		return err
+	}
+	return deleteComment(reviewID, parent)
+}
+
+// commentTarget loads review, which user can comment. If error occurs, writes error message to response writer
+func commentTarget(w http.ResponseWriter, login string, reviewID int) (store.Review, error) {
+	target, err := review.CommentTarget(reviewID, login)
+	if err != nil {
+		commentTargetError(w, reviewID, err)
+	}
+	return target, err
+}
+
+// commentLines checks that lines exist in revisions of review. If error occurs, writes error message
+// to response writer
+func commentLines(w http.ResponseWriter, target store.Review, lineID, endLineID string) error {
+	err := review.CheckCommentLines(target, lineID, endLineID)
+	if err != nil {
+		commentTargetError(w, target.ID, err)
+	}
+	return err
+}
+
+func commentTargetError(w http.ResponseWriter, reviewID int, err error) {
+	switch {
+	case xerrors.Is(err, storm.ErrNotFound):
+		logrus.Warnf("Cannot find review: %d, error: %+v", reviewID, err)
+		utils.Error(w, utils.JSONErrorResponse{
+			Status:        http.StatusNotFound,
+			Message:       "No review with id: " + strconv.Itoa(reviewID),
+			ClientMessage: "Не удалось найти ревью",
+		})
+	case xerrors.Is(err, review.ErrNoAccess):
+		logrus.Warnf("No access to review %d: %+v", reviewID, err)
+		utils.Error(w, utils.JSONErrorResponse{
+			Status:        http.StatusForbidden,
+			Message:       "No access to this review",
+			ClientMessage: "Комментировать ревью могут только его автор и ревьюеры",
+		})
+	case xerrors.Is(err, review.ErrUnknownLine):
+		logrus.Warnf("Cannot find line in review %d: %+v", reviewID, err)
+		utils.Error(w, utils.JSONErrorResponse{
+			Status:        http.StatusBadRequest,
+			Message:       "No such line in review",
+			ClientMessage: "Строка отсутствует в ревизиях ревью",
+		})
+	case xerrors.Is(err, review.ErrInvalidRange):
+		logrus.Warnf("Incorrect range of lines in review %d: %+v", reviewID, err)
+		utils.Error(w, utils.JSONErrorResponse{
+			Status:        http.StatusBadRequest,
+			Message:       "Invalid range of lines",
+			ClientMessage: "Выбранные строки не образуют диапазон в одной ревизии файла",
+		})
+	default:
+		logrus.Errorf("Cannot load review %d: %+v", reviewID, err)
+		utils.Error(w, utils.InternalErrorResponse("Cannot load review"))
+	}
+}
+
+// ResolveComment marks thread of comments as resolved or unresolved
+var ResolveComment = auth.Required(func(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.UserFromRequest(r)
+	if err != nil {
+		logrus.Errorf("Error while getting user from request context: %+v", err)
+		utils.Error(w, utils.InternalErrorResponse("No authorized user for this request"))
+		return
+	}
+
+	var form struct {
+		commentForm
+		Resolved bool `json:"resolved"`
+	}
+	if err := utils.UnmarshalForm(w, r, &form); err != nil {
+		return
+	}
+	if _, err := commentTarget(w, user.Login, form.ReviewID); err != nil {
+		return
+	}
+	comment, err := store.Comments.FindCommentByID(form.ReviewID, form.CommentID)
+	// Thread with deleted root comment cannot be resolved, it is not counted as unresolved
+	if err == nil && comment.Deleted {
+		err = storm.ErrNotFound
+	}
+	if err != nil {
+		logrus.Warnf("Cannot find comment %d in review %d: %+v", form.CommentID, form.ReviewID, err)
+		utils.Error(w, utils.JSONErrorResponse{
+			Status:        http.StatusNotFound,
+			Message:       "No such comment",
+			ClientMessage: "Не удалось найти комментарий",
+		})
+		return
+	}
+	if comment.ParentID != 0 {
+		logrus.Warnf("User %s tries to resolve reply %d", user.Login, comment.ID)
+		utils.Error(w, utils.JSONErrorResponse{
+			Status:        http.StatusBadRequest,
+			Message:       "Only root comments can be resolved",
+			ClientMessage: "Решённым можно отметить только обсуждение целиком",
+		})
+		return
+	}
+	if comment.Resolved == form.Resolved {
+		utils.Ok(w, nil)
+		return
+	}
+
+	comment.Resolved = form.Resolved
+	comment.ResolvedBy, comment.ResolvedAt = "", 0
+	eventType := store.EventThreadUnresolved
+	if form.Resolved {
+		comment.ResolvedBy, comment.ResolvedAt = user.Login, time.Now().Unix()
+		eventType = store.EventThreadResolved
+	}
+	err = store.Comments.UpdateComment(form.ReviewID, &comment)
+	if err != nil {
+		logrus.Errorf("Cannot save resolved comment: %+v", err)
+		utils.Error(w, utils.InternalErrorResponse("Cannot save comment to database"))
+		return
+	}
+	saveCommentEvent(form.ReviewID, eventType, comment, user.Login)
 	utils.Ok(w, nil)
 })
@@ -0,0 +1,168 @@
+package comments
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/dbeliakov/revisor/api/config"
+	"github.com/dbeliakov/revisor/api/review"
+	"github.com/dbeliakov/revisor/api/store"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	owner    = store.User{Login: "ivanov", FirstName: "Иван", LastName: "Иванов"}
+	reviewer = store.User{Login: "petrov", FirstName: "Петр", LastName: "Петров"}
+	stranger = store.User{Login: "sidorov", FirstName: "Михаил", LastName: "Сидоров"}
+)
+
+// initTestStore opens database in temporary directory and creates review with single line
+func initTestStore(t *testing.T) (*storm.DB, store.Review, string) {
+	db, err := storm.Open(filepath.Join(t.TempDir(), "test_database.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, store.InitStoreWithDB(db))
+	for _, user := range []store.User{owner, reviewer, stranger} {
+		require.NoError(t, store.Auth.CreateUser(user))
+	}
+
+	files, err := review.NewVersionedFiles([]review.UploadedFile{
+		{Name: "main.cpp", Content: []string{"int main() {}\n"}},
+	}, review.RevisionInfo{})
+	require.NoError(t, err)
+	content, err := json.Marshal(&files)
+	require.NoError(t, err)
+	file := store.VersionedFile{Content: content}
+	require.NoError(t, store.Files.CreateFile(&file))
+	r := store.Review{Name: "Review", Owner: owner.Login, Reviewers: []string{reviewer.Login}, FileID: file.ID}
+	require.NoError(t, store.Reviews.CreateReview(&r))
+
+	revision, err := files.Files[0].GetRevision(0)
+	require.NoError(t, err)
+	return db, r, revision.Lines[0].ID
+}
+
+// post form to handler on behalf of user and returns response status
+func post(t *testing.T, handler http.HandlerFunc, user store.User, form interface{}) int {
+	body, err := json.Marshal(form)
+	require.NoError(t, err)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"first_name": user.FirstName,
+		"last_name":  user.LastName,
+		"login":      user.Login,
+		"exp":        time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(config.SecretKey))
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, "/comments/add", bytes.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w.Code
+}
+
+// hasNode checks if database contains node with comments of review
+func hasNode(db *storm.DB, reviewID int) bool {
+	name := strconv.Itoa(reviewID)
+	return len(db.RangeScan(name, name)) > 0
+}
+
+func TestAddCommentAccess(t *testing.T) {
+	db, r, lineID := initTestStore(t)
+	comment := func(reviewID int, line string, parent *int) map[string]interface{} {
+		form := map[string]interface{}{"review_id": reviewID, "line_id": line, "text": "Comment"}
+		if parent != nil {
+			form["parent"] = *parent
+		}
+		return form
+	}
+	missing := 1000
+
+	// Nonexistent review
+	assert.Equal(t, http.StatusNotFound, post(t, AddComment, owner, comment(r.ID+1, lineID, nil)))
+	assert.Equal(t, http.StatusNotFound, post(t, AddComment, owner, comment(r.ID+1, "", &missing)))
+	assert.False(t, hasNode(db, r.ID+1))
+
+	// User does not participate in review, whether parent comment exists or not
+	assert.Equal(t, http.StatusForbidden, post(t, AddComment, stranger, comment(r.ID, lineID, nil)))
+	assert.Equal(t, http.StatusForbidden, post(t, AddComment, stranger, comment(r.ID, "", &missing)))
+
+	// Unknown line and range
+	assert.Equal(t, http.StatusBadRequest, post(t, AddComment, reviewer, comment(r.ID, "unknown", nil)))
+	form := comment(r.ID, lineID, nil)
+	form["end_line_id"] = "unknown"
+	assert.Equal(t, http.StatusBadRequest, post(t, AddComment, reviewer, form))
+	assert.Equal(t, http.StatusBadRequest, post(t, AddComment, reviewer, comment(r.ID, lineID, &missing)))
+	assert.False(t, hasNode(db, r.ID))
+
+	assert.Equal(t, http.StatusOK, post(t, AddComment, reviewer, comment(r.ID, lineID, nil)))
+	assert.True(t, hasNode(db, r.ID))
+	assert.Equal(t, http.StatusOK, post(t, AddComment, owner, comment(r.ID, "", nil)))
+	comments, err := store.Comments.CommentsForReview(r.ID)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(comments))
+
+	// Reply is attached to lines of parent comment
+	parent := comments[0].ID
+	assert.Equal(t, http.StatusOK, post(t, AddComment, owner, comment(r.ID, "", &parent)))
+	comments, err = store.Comments.CommentsForReview(r.ID)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(comments))
+	assert.Equal(t, lineID, comments[2].LineID)
+}
+
+func TestChangeCommentAccess(t *testing.T) {
+	_, r, lineID := initTestStore(t)
+	form := map[string]interface{}{"review_id": r.ID, "line_id": lineID, "text": "Comment"}
+	require.Equal(t, http.StatusOK, post(t, AddComment, reviewer, form))
+	comments, err := store.Comments.CommentsForReview(r.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(comments))
+	edit := map[string]interface{}{"review_id": r.ID, "comment_id": comments[0].ID, "text": "Edited"}
+	remove := map[string]interface{}{"review_id": r.ID, "comment_id": comments[0].ID}
+
+	assert.Equal(t, http.StatusForbidden, post(t, EditComment, owner, edit))
+	assert.Equal(t, http.StatusOK, post(t, EditComment, reviewer, edit))
+
+	// Author, who is removed from reviewers, cannot change comment
+	r.Reviewers = nil
+	require.NoError(t, store.Reviews.UpdateReview(&r))
+	assert.Equal(t, http.StatusForbidden, post(t, EditComment, reviewer, edit))
+	assert.Equal(t, http.StatusForbidden, post(t, DeleteComment, reviewer, remove))
+	comment, err := store.Comments.FindCommentByID(r.ID, comments[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Edited", comment.Text)
+	assert.False(t, comment.Deleted)
+}
+
+func TestResolveDeletedThread(t *testing.T) {
+	_, r, lineID := initTestStore(t)
+	form := map[string]interface{}{"review_id": r.ID, "line_id": lineID, "text": "Comment"}
+	require.Equal(t, http.StatusOK, post(t, AddComment, reviewer, form))
+	comments, err := store.Comments.CommentsForReview(r.ID)
+	require.NoError(t, err)
+	root := comments[0].ID
+	form["parent"] = root
+	require.Equal(t, http.StatusOK, post(t, AddComment, owner, form))
+
+	resolve := map[string]interface{}{"review_id": r.ID, "comment_id": root, "resolved": false}
+	require.Equal(t, http.StatusOK, post(t, ResolveComment, owner, resolve))
+
+	// Root comment with reply is replaced with tombstone
+	require.Equal(t, http.StatusOK, post(t, DeleteComment, reviewer, map[string]interface{}{"review_id": r.ID, "comment_id": root}))
+	resolve["resolved"] = true
+	assert.Equal(t, http.StatusNotFound, post(t, ResolveComment, owner, resolve))
+	comment, err := store.Comments.FindCommentByID(r.ID, root)
+	require.NoError(t, err)
+	assert.True(t, comment.Deleted)
+	assert.False(t, comment.Resolved)
+}
@@ -4,6 +4,7 @@ import (
 	"flag"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 )
@@ -17,6 +18,28 @@ var (
 	ClientFilesDir = "./client"
 	// DatabaseFile path to database
 	DatabaseFile = "/database/revisor.db"
+	// ArchiveIgnorePatterns for files and directories, which are skipped while unpacking archives
+	ArchiveIgnorePatterns = []string{
+		".git", ".svn", ".idea", ".vscode", "__MACOSX", ".DS_Store",
+		"build", "bin", "obj", "cmake-build-*",
+		"*.o", "*.obj", "*.a", "*.so", "*.dll", "*.exe", "*.out", "*.class", "*.jar", "*.pyc",
+	}
+	// ApprovalPolicy used for reviews by default: "all", "any" or "count"
+	ApprovalPolicy = "any"
+	// RequiredApprovals for reviews with "count" policy used by default
+	RequiredApprovals = 1
+	// DiffAlgorithm used by default: "difflib", "myers", "patience" or "histogram"
+	DiffAlgorithm = "difflib"
+	// MaxArchiveSize in bytes
+	MaxArchiveSize = 10 << 20
+	// MaxArchiveFiles count of files in archive
+	MaxArchiveFiles = 100
+	// MaxUnpackedSize total size of unpacked files in bytes
+	MaxUnpackedSize = 20 << 20
+	// MaxFileSize of uploaded file in bytes
+	MaxFileSize = 5 << 20
+	// MaxFileLines count of lines in uploaded text file
+	MaxFileLines = 20000
 )
 
 func updateFromEnv(val *string, key string) {
@@ -38,6 +61,31 @@ func updateBoolFromEnv(val *bool, key string) {
 	}
 }
 
+func updateIntFromEnv(val *int, key string) {
+	v := os.Getenv(key)
+	if len(v) > 0 {
+		parsed, err := strconv.Atoi(v)
+		if err != nil {
+			logrus.Warnf("Cannot parse env variable \"%s\": %+v", key, err)
+			return
+		}
+		*val = parsed
+	}
+}
+
+func updateListFromEnv(val *[]string, key string) {
+	v, ok := os.LookupEnv(key)
+	if ok {
+		*val = make([]string, 0)
+		for _, item := range strings.Split(v, ",") {
+			item = strings.TrimSpace(item)
+			if len(item) > 0 {
+				*val = append(*val, item)
+			}
+		}
+	}
+}
+
 func init() {
 	if flag.Lookup("test.v") != nil {
 		Debug = true
@@ -49,4 +97,13 @@ func init() {
 		DatabaseFile = "./revisor.db"
 	}
 	updateFromEnv(&DatabaseFile, "DATABASE_FILE")
+	updateFromEnv(&ApprovalPolicy, "APPROVAL_POLICY")
+	updateIntFromEnv(&RequiredApprovals, "REQUIRED_APPROVALS")
+	updateFromEnv(&DiffAlgorithm, "DIFF_ALGORITHM")
+	updateListFromEnv(&ArchiveIgnorePatterns, "ARCHIVE_IGNORE_PATTERNS")
+	updateIntFromEnv(&MaxArchiveSize, "MAX_ARCHIVE_SIZE")
+	updateIntFromEnv(&MaxArchiveFiles, "MAX_ARCHIVE_FILES")
+	updateIntFromEnv(&MaxUnpackedSize, "MAX_UNPACKED_SIZE")
+	updateIntFromEnv(&MaxFileSize, "MAX_FILE_SIZE")
+	updateIntFromEnv(&MaxFileLines, "MAX_FILE_LINES")
 }
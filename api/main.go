@@ -29,10 +29,19 @@ func addAPIHandlers(base string, r *mux.Router) {
 	r.HandleFunc(base+"/reviews/{id}/update", review.UpdateReview).Methods("POST")
 	r.HandleFunc(base+"/reviews/{id}/accept", review.Accept).Methods("POST")
 	r.HandleFunc(base+"/reviews/{id}/decline", review.Decline).Methods("POST")
+	r.HandleFunc(base+"/reviews/{id}/reopen", review.Reopen).Methods("POST")
+	r.HandleFunc(base+"/reviews/{id}/request_changes", review.RequestChanges).Methods("POST")
+	r.HandleFunc(base+"/reviews/{id}/events", review.Events).Methods("GET")
+	r.HandleFunc(base+"/reviews/{id}/lines", review.HiddenLines).Methods("GET")
+	r.HandleFunc(base+"/reviews/{id}/patch", review.Patch).Methods("GET")
+	r.HandleFunc(base+"/reviews/{id}/revisions/{rev}/raw", review.RawRevision).Methods("GET")
 	r.HandleFunc(base+"/users/search", review.SearchReviewer).Methods("GET")
 
 	// Comments handlers
 	r.HandleFunc(base+"/comments/add", comments.AddComment).Methods("POST")
+	r.HandleFunc(base+"/comments/edit", comments.EditComment).Methods("POST")
+	r.HandleFunc(base+"/comments/delete", comments.DeleteComment).Methods("POST")
+	r.HandleFunc(base+"/comments/resolve", comments.ResolveComment).Methods("POST")
 }
 
 func addClientFilesHandlers(r *mux.Router) {
@@ -0,0 +1,375 @@
+package review
+
+import (
+	"github.com/dbeliakov/revisor/api/config"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/xerrors"
+)
+
+// DiffAlgorithm matches lines of two files
+type DiffAlgorithm interface {
+	OpCodes(a, b []string) []difflib.OpCode
+}
+
+var (
+	// ErrUnknownAlgorithm error
+	ErrUnknownAlgorithm = xerrors.New("Unknown diff algorithm")
+
+	diffAlgorithms = map[string]DiffAlgorithm{
+		"difflib":   sequenceMatcher{},
+		"myers":     matchingAlgorithm(myersMatches),
+		"patience":  matchingAlgorithm(patienceMatches),
+		"histogram": matchingAlgorithm(histogramMatches),
+	}
+)
+
+// algorithmByName returns diff algorithm. Empty name means server default
+func algorithmByName(name string) (DiffAlgorithm, error) {
+	if len(name) == 0 {
+		return defaultAlgorithm(), nil
+	}
+	algorithm, ok := diffAlgorithms[name]
+	if !ok {
+		return nil, xerrors.Errorf("algorithm %s: %w", name, ErrUnknownAlgorithm)
+	}
+	return algorithm, nil
+}
+
+// defaultAlgorithm returns diff algorithm from server config
+func defaultAlgorithm() DiffAlgorithm {
+	algorithm, ok := diffAlgorithms[config.DiffAlgorithm]
+	if !ok {
+		logrus.Warnf("Unknown diff algorithm in config: %s, difflib is used", config.DiffAlgorithm)
+		return sequenceMatcher{}
+	}
+	return algorithm
+}
+
+// sequenceMatcher uses SequenceMatcher from difflib
+type sequenceMatcher struct{}
+
+func (sequenceMatcher) OpCodes(a, b []string) []difflib.OpCode {
+	return difflib.NewMatcher(a, b).GetOpCodes()
+}
+
+// match is a pair of equal lines
+type match struct {
+	I int
+	J int
+}
+
+// sequences are compared lines, which are replaced with numbers, so equal lines have equal numbers
+type sequences struct {
+	A []int
+	B []int
+}
+
+func newSequences(a, b []string) sequences {
+	ids := make(map[string]int)
+	convert := func(lines []string) []int {
+		result := make([]int, 0, len(lines))
+		for _, line := range lines {
+			id, ok := ids[line]
+			if !ok {
+				id = len(ids)
+				ids[line] = id
+			}
+			result = append(result, id)
+		}
+		return result
+	}
+	return sequences{A: convert(a), B: convert(b)}
+}
+
+// matchFunc appends matched lines from ranges [aLo, aHi) and [bLo, bHi) to result in increasing order
+type matchFunc func(s sequences, aLo, aHi, bLo, bHi int, result []match) []match
+
+// matchingAlgorithm is diff algorithm, which finds pairs of matched lines
+type matchingAlgorithm matchFunc
+
+func (algorithm matchingAlgorithm) OpCodes(a, b []string) []difflib.OpCode {
+	s := newSequences(a, b)
+	matches := algorithm(s, 0, len(a), 0, len(b), make([]match, 0))
+	return opCodes(matches, len(a), len(b))
+}
+
+// opCodes converts sorted matched lines to opcodes in the same format as difflib does
+func opCodes(matches []match, n, m int) []difflib.OpCode {
+	result := make([]difflib.OpCode, 0)
+	i, j := 0, 0
+	// Sentinel match at the end of both sequences
+	matches = append(matches, match{I: n, J: m})
+	for k := 0; k < len(matches); {
+		mi, mj := matches[k].I, matches[k].J
+		var tag byte
+		switch {
+		case i < mi && j < mj:
+			tag = 'r'
+		case i < mi:
+			tag = 'd'
+		case j < mj:
+			tag = 'i'
+		}
+		if tag != 0 {
+			result = append(result, difflib.OpCode{Tag: tag, I1: i, I2: mi, J1: j, J2: mj})
+		}
+		if k == len(matches)-1 {
+			break
+		}
+		// Consecutive matches form one equal block
+		size := 1
+		for k+size < len(matches)-1 && matches[k+size].I == mi+size && matches[k+size].J == mj+size {
+			size++
+		}
+		result = append(result, difflib.OpCode{Tag: 'e', I1: mi, I2: mi + size, J1: mj, J2: mj + size})
+		i, j = mi+size, mj+size
+		k += size
+	}
+	return result
+}
+
+// commonAffixes returns lengths of common prefix and common suffix of ranges
+func commonAffixes(s sequences, aLo, aHi, bLo, bHi int) (int, int) {
+	prefix := 0
+	for aLo+prefix < aHi && bLo+prefix < bHi && s.A[aLo+prefix] == s.B[bLo+prefix] {
+		prefix++
+	}
+	suffix := 0
+	for aHi-suffix > aLo+prefix && bHi-suffix > bLo+prefix && s.A[aHi-suffix-1] == s.B[bHi-suffix-1] {
+		suffix++
+	}
+	return prefix, suffix
+}
+
+// withAffixes matches common prefix and suffix of ranges and matches the rest with specified function
+func withAffixes(s sequences, aLo, aHi, bLo, bHi int, result []match, f matchFunc) []match {
+	prefix, suffix := commonAffixes(s, aLo, aHi, bLo, bHi)
+	for k := 0; k < prefix; k++ {
+		result = append(result, match{I: aLo + k, J: bLo + k})
+	}
+	aLo, bLo = aLo+prefix, bLo+prefix
+	aHi, bHi = aHi-suffix, bHi-suffix
+	if aLo < aHi && bLo < bHi {
+		result = f(s, aLo, aHi, bLo, bHi, result)
+	}
+	for k := 0; k < suffix; k++ {
+		result = append(result, match{I: aHi + k, J: bHi + k})
+	}
+	return result
+}
+
+// myersMatches uses linear space variation of Myers algorithm
+func myersMatches(s sequences, aLo, aHi, bLo, bHi int, result []match) []match {
+	return withAffixes(s, aLo, aHi, bLo, bHi, result, func(s sequences, aLo, aHi, bLo, bHi int, result []match) []match {
+		x, y, u, v := middleSnake(s.A[aLo:aHi], s.B[bLo:bHi])
+		result = myersMatches(s, aLo, aLo+x, bLo, bLo+y, result)
+		for k := 0; k < u-x; k++ {
+			result = append(result, match{I: aLo + x + k, J: bLo + y + k})
+		}
+		return myersMatches(s, aLo+u, aHi, bLo+v, bHi, result)
+	})
+}
+
+// middleSnake finds the middle snake of the shortest edit script from a to b. Snake starts at (x, y)
+// and ends at (u, v). Sequences must differ both in the first and in the last elements
+func middleSnake(a, b []int) (int, int, int, int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	limit := (n + m + 1) / 2
+	offset := limit + 1
+	forward := make([]int, 2*limit+3)
+	backward := make([]int, 2*limit+3)
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+			if delta%2 != 0 && delta-k >= -(d-1) && delta-k <= d-1 && x+backward[offset+delta-k] >= n {
+				return startX, startY, x, y
+			}
+		}
+		for k := -d; k <= d; k += 2 {
+			// Paths are calculated from the end of sequences, x and y are distances from the end
+			var x int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+			if delta%2 == 0 && delta-k >= -d && delta-k <= d && x+forward[offset+delta-k] >= n {
+				return n - x, m - y, n - startX, m - startY
+			}
+		}
+	}
+	// Unreachable: paths always overlap after (n + m + 1) / 2 steps
+	return 0, 0, 0, 0
+}
+
+// patienceMatches uses patience algorithm: lines, which are unique in both ranges, are matched first.
+// Myers algorithm is used for ranges without such lines
+func patienceMatches(s sequences, aLo, aHi, bLo, bHi int, result []match) []match {
+	return withAffixes(s, aLo, aHi, bLo, bHi, result, func(s sequences, aLo, aHi, bLo, bHi int, result []match) []match {
+		anchors := uniqueMatches(s, aLo, aHi, bLo, bHi)
+		if len(anchors) == 0 {
+			return myersMatches(s, aLo, aHi, bLo, bHi, result)
+		}
+		i, j := aLo, bLo
+		for _, anchor := range anchors {
+			result = patienceMatches(s, i, anchor.I, j, anchor.J, result)
+			result = append(result, anchor)
+			i, j = anchor.I+1, anchor.J+1
+		}
+		return patienceMatches(s, i, aHi, j, bHi, result)
+	})
+}
+
+// uniqueMatches returns the longest increasing sequence of pairs of lines, which are unique in both ranges
+func uniqueMatches(s sequences, aLo, aHi, bLo, bHi int) []match {
+	type occurrence struct {
+		CountA, CountB int
+		I, J           int
+	}
+	occurrences := make(map[int]*occurrence)
+	for i := aLo; i < aHi; i++ {
+		o, ok := occurrences[s.A[i]]
+		if !ok {
+			o = &occurrence{}
+			occurrences[s.A[i]] = o
+		}
+		o.CountA++
+		o.I = i
+	}
+	for j := bLo; j < bHi; j++ {
+		if o, ok := occurrences[s.B[j]]; ok {
+			o.CountB++
+			o.J = j
+		}
+	}
+	candidates := make([]match, 0)
+	for i := aLo; i < aHi; i++ {
+		o := occurrences[s.A[i]]
+		if o.CountA == 1 && o.CountB == 1 {
+			candidates = append(candidates, match{I: o.I, J: o.J})
+		}
+	}
+	return longestIncreasing(candidates)
+}
+
+// longestIncreasing returns the longest subsequence of matches sorted by I, which is increasing by J
+func longestIncreasing(matches []match) []match {
+	// tails[k] is index of the smallest tail of increasing subsequence of length k+1
+	tails := make([]int, 0)
+	previous := make([]int, len(matches))
+	for k, m := range matches {
+		lo, hi := 0, len(tails)
+		for lo < hi {
+			mid := (lo + hi) / 2
+			if matches[tails[mid]].J < m.J {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		}
+		previous[k] = -1
+		if lo > 0 {
+			previous[k] = tails[lo-1]
+		}
+		if lo == len(tails) {
+			tails = append(tails, k)
+		} else {
+			tails[lo] = k
+		}
+	}
+	result := make([]match, len(tails))
+	if len(tails) == 0 {
+		return result
+	}
+	idx := tails[len(tails)-1]
+	for k := len(tails) - 1; k >= 0; k-- {
+		result[k] = matches[idx]
+		idx = previous[idx]
+	}
+	return result
+}
+
+// maxHistogramChain limits count of occurrences of line, which may be used by histogram algorithm
+const maxHistogramChain = 64
+
+// histogramMatches uses histogram algorithm: the longest common region, which contains the least
+// frequent lines, is matched first. Myers algorithm is used for ranges with only frequent lines
+func histogramMatches(s sequences, aLo, aHi, bLo, bHi int, result []match) []match {
+	return withAffixes(s, aLo, aHi, bLo, bHi, result, func(s sequences, aLo, aHi, bLo, bHi int, result []match) []match {
+		positions := make(map[int][]int)
+		for i := aLo; i < aHi; i++ {
+			positions[s.A[i]] = append(positions[s.A[i]], i)
+		}
+
+		found := false
+		common := false
+		var bestI, bestJ, bestLen int
+		bestCount := maxHistogramChain + 1
+		for j := bLo; j < bHi; j++ {
+			occurrences := positions[s.B[j]]
+			if len(occurrences) == 0 {
+				continue
+			}
+			common = true
+			if len(occurrences) > maxHistogramChain || len(occurrences) > bestCount {
+				continue
+			}
+			for _, i := range occurrences {
+				// Extend region in both directions
+				startI, startJ := i, j
+				for startI > aLo && startJ > bLo && s.A[startI-1] == s.B[startJ-1] {
+					startI--
+					startJ--
+				}
+				endI, endJ := i+1, j+1
+				for endI < aHi && endJ < bHi && s.A[endI] == s.B[endJ] {
+					endI++
+					endJ++
+				}
+				count := len(occurrences)
+				for k := startI; k < endI; k++ {
+					if c := len(positions[s.A[k]]); c < count {
+						count = c
+					}
+				}
+				if endI-startI > bestLen || count < bestCount {
+					found = true
+					bestI, bestJ, bestLen, bestCount = startI, startJ, endI-startI, count
+				}
+			}
+		}
+		if !found {
+			if common {
+				return myersMatches(s, aLo, aHi, bLo, bHi, result)
+			}
+			return result
+		}
+
+		result = histogramMatches(s, aLo, bestI, bLo, bestJ, result)
+		for k := 0; k < bestLen; k++ {
+			result = append(result, match{I: bestI + k, J: bestJ + k})
+		}
+		return histogramMatches(s, bestI+bestLen, aHi, bestJ+bestLen, bHi, result)
+	})
+}
@@ -0,0 +1,147 @@
+package review
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/dbeliakov/revisor/api/config"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+)
+
+// checkOpCodes checks, that opcodes cover both sequences and equal blocks contain equal lines.
+// Returns count of matched lines
+func checkOpCodes(t *testing.T, a, b []string, codes []difflib.OpCode) int {
+	i, j, matched := 0, 0, 0
+	for _, c := range codes {
+		require.Equal(t, i, c.I1)
+		require.Equal(t, j, c.J1)
+		switch c.Tag {
+		case 'e':
+			require.Equal(t, c.I2-c.I1, c.J2-c.J1)
+			assert.Equal(t, a[c.I1:c.I2], b[c.J1:c.J2])
+			matched += c.I2 - c.I1
+		case 'r':
+			require.True(t, c.I1 < c.I2 && c.J1 < c.J2)
+		case 'd':
+			require.True(t, c.I1 < c.I2 && c.J1 == c.J2)
+		case 'i':
+			require.True(t, c.I1 == c.I2 && c.J1 < c.J2)
+		}
+		i, j = c.I2, c.J2
+	}
+	assert.Equal(t, len(a), i)
+	assert.Equal(t, len(b), j)
+	return matched
+}
+
+// lcsLength returns length of the longest common subsequence
+func lcsLength(a, b []string) int {
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] > lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+	return lengths[0][0]
+}
+
+func TestAlgorithmsOnRevisions(t *testing.T) {
+	for name, algorithm := range diffAlgorithms {
+		for _, first := range revisions {
+			for _, second := range revisions {
+				a, b := splitLines(first), splitLines(second)
+				matched := checkOpCodes(t, a, b, algorithm.OpCodes(a, b))
+				if name == "myers" {
+					assert.Equal(t, lcsLength(a, b), matched)
+				}
+			}
+		}
+	}
+}
+
+func TestAlgorithmsOnRandom(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	sequence := func() []string {
+		result := make([]string, random.Intn(30))
+		for i := range result {
+			result[i] = string(rune('a' + random.Intn(4)))
+		}
+		return result
+	}
+	for k := 0; k < 500; k++ {
+		a, b := sequence(), sequence()
+		for name, algorithm := range diffAlgorithms {
+			matched := checkOpCodes(t, a, b, algorithm.OpCodes(a, b))
+			if name == "myers" {
+				assert.Equal(t, lcsLength(a, b), matched, "%v %v", a, b)
+			}
+		}
+	}
+}
+
+func TestPatienceBraces(t *testing.T) {
+	a := strings.SplitAfter("void f() {\n  foo();\n}\n\nvoid g() {\n  bar();\n}\n", "\n")
+	b := strings.SplitAfter("void f() {\n  foo();\n}\n\nvoid h() {\n  baz();\n}\n\nvoid g() {\n  bar();\n}\n", "\n")
+	// The whole new function is inserted, g() is matched with its own braces
+	assert.Equal(t, []difflib.OpCode{
+		{Tag: 'e', I1: 0, I2: 4, J1: 0, J2: 4},
+		{Tag: 'i', I1: 4, I2: 4, J1: 4, J2: 8},
+		{Tag: 'e', I1: 4, I2: 8, J1: 8, J2: 12},
+	}, diffAlgorithms["patience"].OpCodes(a, b))
+	assert.Equal(t, diffAlgorithms["patience"].OpCodes(a, b), diffAlgorithms["histogram"].OpCodes(a, b))
+}
+
+func TestAlgorithmByName(t *testing.T) {
+	algorithm, err := algorithmByName("patience")
+	require.NoError(t, err)
+	assert.NotNil(t, algorithm)
+	_, err = algorithmByName("unknown")
+	assert.True(t, xerrors.Is(err, ErrUnknownAlgorithm))
+}
+
+func TestRevisionsWithAlgorithms(t *testing.T) {
+	defer func(algorithm string) { config.DiffAlgorithm = algorithm }(config.DiffAlgorithm)
+	for name := range diffAlgorithms {
+		config.DiffAlgorithm = name
+		file := NewVersionedFile(fileName, splitLines(revisions[0]))
+		for _, content := range revisions[1:] {
+			require.NoError(t, file.AddRevision(splitLines(content)))
+		}
+		for i, content := range revisions {
+			rev, err := file.GetRevision(i)
+			require.NoError(t, err)
+			assert.Equal(t, strings.TrimSpace(content), strings.TrimSpace(rev.Content()), name)
+		}
+	}
+}
+
+func TestAlgorithmsFixtureDiffs(t *testing.T) {
+	names := []string{"first", "second", "third"}
+	for name, algorithm := range diffAlgorithms {
+		file := NewVersionedFile(fileName, splitLines(revisions[0]))
+		for _, content := range revisions[1:] {
+			require.NoError(t, file.AddRevision(splitLines(content)))
+		}
+		for i := range revisions {
+			for j := range revisions {
+				diff, err := file.DiffWithOptions(i, j, DiffOptions{Algorithm: algorithm})
+				require.NoError(t, err)
+				expected := readFile(names[j] + "-" + names[i] + ".diff")
+				assert.Equal(t, strings.TrimSpace(expected), strings.TrimSpace(diff.String()), name)
+			}
+		}
+	}
+}
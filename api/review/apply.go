@@ -0,0 +1,357 @@
+package review
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+var (
+	// ErrIncorrectPatch error
+	ErrIncorrectPatch = xerrors.New("Incorrect patch")
+	// ErrPatchNotApplies error
+	ErrPatchNotApplies = xerrors.New("Patch does not apply")
+
+	hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+)
+
+const devNull = "/dev/null"
+
+// patchLine is a line of hunk: context (' '), deleted ('-') or inserted ('+')
+type patchLine struct {
+	Op      byte
+	Content string
+}
+
+type patchHunk struct {
+	OldFrom  int
+	OldCount int
+	NewFrom  int
+	NewCount int
+	Lines    []patchLine
+}
+
+// filePatch contains changes of one file
+type filePatch struct {
+	OldName string
+	NewName string
+	Added   bool
+	Removed bool
+	// Binary is set, if content of binary file was changed. Such changes cannot be applied
+	Binary bool
+	Hunks  []patchHunk
+}
+
+// HunkError describes hunk of patch, which cannot be applied
+type HunkError struct {
+	File string `json:"file"`
+	// Number of hunk in file starting from 1, zero for errors related to the whole file
+	Hunk    int    `json:"hunk"`
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// PatchError contains all hunks of patch, which cannot be applied
+type PatchError struct {
+	Hunks []HunkError
+}
+
+func (e *PatchError) Error() string {
+	messages := make([]string, 0, len(e.Hunks))
+	for _, h := range e.Hunks {
+		messages = append(messages, fmt.Sprintf("%s, hunk %d, line %d: %s", h.File, h.Hunk, h.Line, h.Message))
+	}
+	return "Patch does not apply: " + strings.Join(messages, "; ")
+}
+
+// Is allows to check PatchError with ErrPatchNotApplies
+func (e *PatchError) Is(target error) bool {
+	return target == ErrPatchNotApplies
+}
+
+// patchPath removes prefix and timestamp from path of file in header of patch
+func patchPath(header, prefix string) string {
+	path := strings.TrimRight(header, "\r\n")
+	if tab := strings.Index(path, "\t"); tab >= 0 {
+		path = path[:tab]
+	}
+	if path == devNull {
+		return path
+	}
+	return strings.TrimPrefix(path, prefix)
+}
+
+func parseCount(value string) int {
+	if len(value) == 0 {
+		return 1
+	}
+	count, _ := strconv.Atoi(value)
+	return count
+}
+
+// parsePatch in unified diff format. Git extended headers for added, removed and renamed files are supported
+func parsePatch(patch string) ([]filePatch, error) {
+	lines := strings.SplitAfter(patch, "\n")
+	result := make([]filePatch, 0)
+	var current *filePatch
+	// Header of file started by "diff --git" is not finished until the first hunk
+	gitHeader := false
+	for k := 0; k < len(lines); k++ {
+		line := lines[k]
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			names := strings.TrimSpace(strings.TrimPrefix(line, "diff --git "))
+			separator := strings.LastIndex(names, " b/")
+			if separator < 0 {
+				return nil, xerrors.Errorf("line %d: %w", k+1, ErrIncorrectPatch)
+			}
+			result = append(result, filePatch{
+				OldName: strings.TrimPrefix(names[:separator], "a/"),
+				NewName: names[separator+3:],
+			})
+			current = &result[len(result)-1]
+			gitHeader = true
+		case current != nil && gitHeader && strings.HasPrefix(line, "new file mode"):
+			current.Added = true
+		case current != nil && gitHeader && strings.HasPrefix(line, "deleted file mode"):
+			current.Removed = true
+		case current != nil && gitHeader && strings.HasPrefix(line, "rename from "):
+			current.OldName = strings.TrimSpace(strings.TrimPrefix(line, "rename from "))
+		case current != nil && gitHeader && strings.HasPrefix(line, "rename to "):
+			current.NewName = strings.TrimSpace(strings.TrimPrefix(line, "rename to "))
+		case current != nil && (strings.HasPrefix(line, "Binary files ") || strings.HasPrefix(line, "GIT binary patch")):
+			current.Binary = true
+		case strings.HasPrefix(line, "--- "):
+			if !gitHeader {
+				result = append(result, filePatch{})
+				current = &result[len(result)-1]
+			}
+			if path := patchPath(line[4:], "a/"); path == devNull {
+				current.Added = true
+			} else {
+				current.OldName = path
+			}
+		case current != nil && strings.HasPrefix(line, "+++ "):
+			if path := patchPath(line[4:], "b/"); path == devNull {
+				current.Removed = true
+			} else {
+				current.NewName = path
+			}
+		case strings.HasPrefix(line, "@@"):
+			if current == nil {
+				return nil, xerrors.Errorf("line %d, hunk without file: %w", k+1, ErrIncorrectPatch)
+			}
+			gitHeader = false
+			groups := hunkHeader.FindStringSubmatch(line)
+			if groups == nil {
+				return nil, xerrors.Errorf("line %d, incorrect hunk header: %w", k+1, ErrIncorrectPatch)
+			}
+			h := patchHunk{
+				OldCount: parseCount(groups[2]),
+				NewCount: parseCount(groups[4]),
+			}
+			h.OldFrom, _ = strconv.Atoi(groups[1])
+			h.NewFrom, _ = strconv.Atoi(groups[3])
+			oldLeft, newLeft := h.OldCount, h.NewCount
+			for oldLeft > 0 || newLeft > 0 {
+				k++
+				if k >= len(lines) || len(lines[k]) == 0 {
+					return nil, xerrors.Errorf("line %d, unexpected end of hunk: %w", k+1, ErrIncorrectPatch)
+				}
+				l := lines[k]
+				op := l[0]
+				content := l[1:]
+				if l == "\n" || l == "\r\n" {
+					// Some editors remove trailing spaces of empty context lines
+					op, content = ' ', l
+				}
+				switch op {
+				case ' ':
+					oldLeft--
+					newLeft--
+				case '-':
+					oldLeft--
+				case '+':
+					newLeft--
+				case '\\':
+					markNoNewline(&h)
+					continue
+				default:
+					return nil, xerrors.Errorf("line %d, unexpected line in hunk: %w", k+1, ErrIncorrectPatch)
+				}
+				if oldLeft < 0 || newLeft < 0 {
+					return nil, xerrors.Errorf("line %d, hunk is longer than header says: %w", k+1, ErrIncorrectPatch)
+				}
+				h.Lines = append(h.Lines, patchLine{Op: op, Content: content})
+			}
+			if k+1 < len(lines) && strings.HasPrefix(lines[k+1], "\\") {
+				k++
+				markNoNewline(&h)
+			}
+			current.Hunks = append(current.Hunks, h)
+		}
+	}
+	if len(result) == 0 {
+		return nil, xerrors.Errorf("no files: %w", ErrIncorrectPatch)
+	}
+	return result, nil
+}
+
+// markNoNewline removes newline from the last line of hunk
+func markNoNewline(h *patchHunk) {
+	if len(h.Lines) > 0 {
+		last := &h.Lines[len(h.Lines)-1]
+		last.Content = strings.TrimSuffix(last.Content, "\n")
+	}
+}
+
+// matchesAt checks if lines of file starting from position are equal to expected ones
+func matchesAt(lines []string, position int, expected []string) bool {
+	if position < 0 || position+len(expected) > len(lines) {
+		return false
+	}
+	for i, line := range expected {
+		if lines[position+i] != line {
+			return false
+		}
+	}
+	return true
+}
+
+// applyHunks to lines of file. Hunks are searched near their expected positions, if file was changed
+func applyHunks(name string, lines []string, hunks []patchHunk) ([]string, []HunkError) {
+	result := make([]string, 0, len(lines))
+	errors := make([]HunkError, 0)
+	// Lines [0, done) of original file are already processed
+	done, offset := 0, 0
+	for n, h := range hunks {
+		old := make([]string, 0, h.OldCount)
+		for _, l := range h.Lines {
+			if l.Op != '+' {
+				old = append(old, l.Content)
+			}
+		}
+		expected := h.OldFrom - 1 + offset
+		if h.OldCount == 0 {
+			expected = h.OldFrom + offset
+		}
+		position := -1
+		for distance := 0; position < 0 && (expected-distance >= done || expected+distance <= len(lines)); distance++ {
+			if expected-distance >= done && matchesAt(lines, expected-distance, old) {
+				position = expected - distance
+			} else if expected+distance >= done && matchesAt(lines, expected+distance, old) {
+				position = expected + distance
+			}
+		}
+		if position < 0 {
+			errors = append(errors, HunkError{
+				File:    name,
+				Hunk:    n + 1,
+				Line:    h.OldFrom,
+				Message: "Context does not match",
+			})
+			continue
+		}
+		offset = position - (expected - offset)
+		result = append(result, lines[done:position]...)
+		for _, l := range h.Lines {
+			if l.Op != '-' {
+				result = append(result, l.Content)
+			}
+		}
+		done = position + len(old)
+	}
+	return append(result, lines[done:]...), errors
+}
+
+// ApplyPatch to the last revision of review. Returns all files of new revision
+func (files *VersionedFiles) ApplyPatch(patch string) ([]UploadedFile, error) {
+	patches, err := parsePatch(patch)
+	if err != nil {
+		return nil, err
+	}
+	last, err := files.GetRevision(files.RevisionsCount() - 1)
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[string]File)
+	frs := make(map[string]FileRevision)
+	for _, fr := range last.Files {
+		frs[fr.Name] = fr
+		file, err := files.Files[fr.File].GetRevision(fr.Revision)
+		if err != nil {
+			return nil, err
+		}
+		current[fr.Name] = file
+	}
+
+	errors := make([]HunkError, 0)
+	changed := make(map[string]UploadedFile)
+	removed := make(map[string]bool)
+	added := make([]UploadedFile, 0)
+	for _, p := range patches {
+		if p.Binary {
+			errors = append(errors, HunkError{File: p.NewName, Message: ErrBinaryPatch.Error()})
+			continue
+		}
+		if p.Added {
+			content, hunkErrors := applyHunks(p.NewName, []string{}, p.Hunks)
+			errors = append(errors, hunkErrors...)
+			added = append(added, UploadedFile{Name: p.NewName, Content: splitLines(strings.Join(content, ""))})
+			continue
+		}
+		file, exists := current[p.OldName]
+		if !exists || removed[p.OldName] {
+			errors = append(errors, HunkError{File: p.OldName, Message: "No such file in the last revision"})
+			continue
+		}
+		if p.Removed {
+			removed[p.OldName] = true
+			continue
+		}
+		fr := frs[p.OldName]
+		if fr.Binary != nil && len(p.Hunks) > 0 {
+			errors = append(errors, HunkError{File: p.OldName, Message: ErrBinaryPatch.Error()})
+			continue
+		}
+		content, hunkErrors := applyHunks(p.OldName, splitContent(file), p.Hunks)
+		errors = append(errors, hunkErrors...)
+		uploaded := UploadedFile{
+			Name:     p.NewName,
+			Content:  splitLines(strings.Join(content, "")),
+			Encoding: fr.Encoding,
+			BOM:      fr.BOM,
+			Binary:   fr.Binary,
+		}
+		if p.NewName != p.OldName {
+			uploaded.OldName = p.OldName
+		}
+		changed[p.OldName] = uploaded
+	}
+	if len(errors) > 0 {
+		return nil, &PatchError{Hunks: errors}
+	}
+
+	result := make([]UploadedFile, 0, len(last.Files)+len(added))
+	for _, fr := range last.Files {
+		if removed[fr.Name] {
+			continue
+		}
+		if uploaded, ok := changed[fr.Name]; ok {
+			result = append(result, uploaded)
+			continue
+		}
+		// Content of unchanged file is the same as in the last revision
+		result = append(result, UploadedFile{
+			Name:     fr.Name,
+			Content:  splitContent(current[fr.Name]),
+			Encoding: fr.Encoding,
+			BOM:      fr.BOM,
+			Binary:   fr.Binary,
+		})
+	}
+	return append(result, added...), nil
+}
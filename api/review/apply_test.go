@@ -0,0 +1,100 @@
+package review
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+)
+
+// revisionContent returns content of all files of revision by names
+func revisionContent(t *testing.T, files VersionedFiles, revision int) map[string]string {
+	rev, err := files.GetRevision(revision)
+	require.NoError(t, err)
+	result := make(map[string]string)
+	for _, fr := range rev.Files {
+		file, err := files.Files[fr.File].GetRevision(fr.Revision)
+		require.NoError(t, err)
+		result[fr.Name] = strings.Join(splitContent(file), "")
+	}
+	return result
+}
+
+func TestApplyExportedPatch(t *testing.T) {
+	source := patchFiles(t)
+	patch, err := source.Patch(0, 1)
+	require.NoError(t, err)
+
+	files, err := NewVersionedFiles([]UploadedFile{
+		uploaded(fileName, revisions[0]),
+		uploaded(headerName, "int f();\n"),
+		uploaded("removed.txt", "removed\n"),
+	}, RevisionInfo{})
+	require.NoError(t, err)
+	patched, err := files.ApplyPatch(string(patch))
+	require.NoError(t, err)
+	require.NoError(t, files.AddRevision(patched, RevisionInfo{}))
+	assert.Equal(t, revisionContent(t, source, 1), revisionContent(t, files, 1))
+
+	// Renamed file keeps its history
+	rev, err := files.GetRevision(1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, rev.Files[0].Revision)
+	assert.Equal(t, otherName, rev.Files[0].Name)
+	// Unchanged file is not changed
+	assert.Equal(t, FileRevision{
+		File:       1,
+		Revision:   0,
+		Name:       headerName,
+		TextFormat: TextFormat{Encoding: EncodingUTF8, LineEnding: LineEndingLF, FinalNewline: true},
+	}, rev.Files[1])
+}
+
+func TestApplyPlainPatchWithOffset(t *testing.T) {
+	files, err := NewVersionedFiles([]UploadedFile{uploaded(fileName, numberedLines(20))}, RevisionInfo{})
+	require.NoError(t, err)
+	// Line numbers are shifted by two lines
+	patch := "--- main.cpp\t2019-01-01 00:00:00\n+++ main.cpp\t2019-01-02 00:00:00\n" +
+		"@@ -8,3 +8,3 @@\n line 9\n-line 10\n+changed 10\n line 11\n"
+	patched, err := files.ApplyPatch(patch)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(patched))
+	assert.Equal(t, numberedLines(20, 10), strings.Join(patched[0].Content[:20], ""))
+}
+
+func TestApplyPatchErrors(t *testing.T) {
+	files, err := NewVersionedFiles([]UploadedFile{uploaded(fileName, numberedLines(20))}, RevisionInfo{})
+	require.NoError(t, err)
+	patch := "--- a/main.cpp\n+++ b/main.cpp\n" +
+		"@@ -2,2 +2,2 @@\n line 1\n-line 2\n+changed 2\n" +
+		"@@ -10,2 +10,2 @@\n line 9\n-other 10\n+changed 10\n" +
+		"--- a/unknown.cpp\n+++ b/unknown.cpp\n@@ -1 +1 @@\n-a\n+b\n"
+	_, err = files.ApplyPatch(patch)
+	assert.True(t, xerrors.Is(err, ErrPatchNotApplies))
+	var patchErr *PatchError
+	require.True(t, xerrors.As(err, &patchErr))
+	assert.Equal(t, []HunkError{
+		{File: fileName, Hunk: 2, Line: 10, Message: "Context does not match"},
+		{File: "unknown.cpp", Message: "No such file in the last revision"},
+	}, patchErr.Hunks)
+
+	for _, incorrect := range []string{
+		"",
+		"just text\n",
+		"--- a/main.cpp\n+++ b/main.cpp\n@@ -1,2 +1,2 @@\n line 0\n",
+		"--- a/main.cpp\n+++ b/main.cpp\n@@ -1 +1 @@\n-line 0\n-line 1\n+line 1\n",
+		"--- a/main.cpp\n+++ b/main.cpp\n@@ -1 +1 @@\n*line 0\n",
+	} {
+		_, err = files.ApplyPatch(incorrect)
+		assert.True(t, xerrors.Is(err, ErrIncorrectPatch), incorrect)
+	}
+}
+
+func TestParseNoNewline(t *testing.T) {
+	patches, err := parsePatch("--- a/f\n+++ b/f\n@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+b\n\\ No newline at end of file\n")
+	require.NoError(t, err)
+	require.Equal(t, 1, len(patches))
+	assert.Equal(t, []patchLine{{Op: '-', Content: "a"}, {Op: '+', Content: "b"}}, patches[0].Hunks[0].Lines)
+}
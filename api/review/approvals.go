@@ -0,0 +1,131 @@
+package review
+
+import (
+	"time"
+
+	"github.com/dbeliakov/revisor/api/config"
+	"github.com/dbeliakov/revisor/api/store"
+	"golang.org/x/xerrors"
+)
+
+var (
+	// ErrIncorrectPolicy error
+	ErrIncorrectPolicy = xerrors.New("Incorrect approval policy")
+)
+
+// approvalPolicy of review, uses server default for reviews without policy
+func approvalPolicy(review store.Review) store.ApprovalPolicy {
+	if len(review.ApprovalPolicy) > 0 {
+		return review.ApprovalPolicy
+	}
+	return store.ApprovalPolicy(config.ApprovalPolicy)
+}
+
+// setApprovalPolicy checks and sets policy for review. Empty policy means server default
+func setApprovalPolicy(review *store.Review, policy store.ApprovalPolicy, required int) error {
+	switch policy {
+	case "", store.PolicyAll, store.PolicyAny:
+		required = 0
+	case store.PolicyCount:
+		if required < 1 || required > len(review.Reviewers) {
+			return xerrors.Errorf("%d of %d approvals: %w", required, len(review.Reviewers), ErrIncorrectPolicy)
+		}
+	default:
+		return xerrors.Errorf("policy %s: %w", policy, ErrIncorrectPolicy)
+	}
+	review.ApprovalPolicy = policy
+	review.RequiredApprovals = required
+	return nil
+}
+
+// requiredApprovals returns count of approvals required to accept review, at least one
+func requiredApprovals(review store.Review) int {
+	required := 1
+	switch approvalPolicy(review) {
+	case store.PolicyAll:
+		required = len(review.Reviewers)
+	case store.PolicyCount:
+		required = review.RequiredApprovals
+		if required == 0 {
+			// Review uses server default policy, so count of approvals is not stored in it
+			required = config.RequiredApprovals
+		}
+		if required > len(review.Reviewers) {
+			required = len(review.Reviewers)
+		}
+	}
+	if required < 1 {
+		return 1
+	}
+	return required
+}
+
+// currentVerdicts returns verdicts of all reviewers for the last revision
+func currentVerdicts(review store.Review) map[string]store.VerdictStatus {
+	result := make(map[string]store.VerdictStatus)
+	for _, reviewer := range review.Reviewers {
+		result[reviewer] = store.VerdictPending
+	}
+	for _, verdict := range review.Verdicts {
+		if verdict.Revision != review.RevisionsCount-1 {
+			continue
+		}
+		if _, ok := result[verdict.Reviewer]; ok {
+			result[verdict.Reviewer] = verdict.Status
+		}
+	}
+	return result
+}
+
+// approvalsCount returns count of reviewers, who approved the last revision
+func approvalsCount(review store.Review) int {
+	count := 0
+	for _, status := range currentVerdicts(review) {
+		if status == store.VerdictApproved {
+			count++
+		}
+	}
+	return count
+}
+
+// setVerdict of reviewer for the last revision and updates state of review according to policy.
+// Unresolved threads of comments block approvals and any verdict, which accepts review
+func setVerdict(review *store.Review, reviewer string, status store.VerdictStatus, comments []store.Comment) error {
+	if isClosed(reviewState(*review)) {
+		return xerrors.Errorf("verdict for closed review: %w", ErrIncorrectTransition)
+	}
+	revision := review.RevisionsCount - 1
+	verdicts := make([]store.Verdict, 0, len(review.Verdicts)+1)
+	for _, verdict := range review.Verdicts {
+		if verdict.Reviewer != reviewer || verdict.Revision != revision {
+			verdicts = append(verdicts, verdict)
+		}
+	}
+	updated := *review
+	updated.Verdicts = append(verdicts, store.Verdict{
+		Reviewer: reviewer,
+		Revision: revision,
+		Status:   status,
+		Created:  time.Now().Unix(),
+	})
+	accepted := approvalsCount(updated) >= requiredApprovals(updated)
+	if status == store.VerdictApproved || accepted {
+		if err := checkUnresolved(updated, comments); err != nil {
+			return err
+		}
+	}
+	*review = updated
+
+	if accepted {
+		return changeState(review, store.StateAccepted, reviewer)
+	}
+	for _, status := range currentVerdicts(*review) {
+		if status == store.VerdictChangesRequested {
+			return changeState(review, store.StateChangesRequested, reviewer)
+		}
+	}
+	if reviewState(*review) == store.StateChangesRequested {
+		return changeState(review, store.StateOpen, reviewer)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package review
+
+import (
+	"testing"
+
+	"github.com/dbeliakov/revisor/api/config"
+	"github.com/dbeliakov/revisor/api/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+)
+
+func newPolicyReview(t *testing.T, policy store.ApprovalPolicy, required int) store.Review {
+	review := store.Review{
+		Owner:          "owner",
+		Reviewers:      []string{"first", "second", "third"},
+		RevisionsCount: 1,
+	}
+	require.NoError(t, setApprovalPolicy(&review, policy, required))
+	return review
+}
+
+func TestIncorrectPolicy(t *testing.T) {
+	review := newPolicyReview(t, store.PolicyAny, 0)
+	assert.True(t, xerrors.Is(setApprovalPolicy(&review, "unknown", 0), ErrIncorrectPolicy))
+	assert.True(t, xerrors.Is(setApprovalPolicy(&review, store.PolicyCount, 0), ErrIncorrectPolicy))
+	assert.True(t, xerrors.Is(setApprovalPolicy(&review, store.PolicyCount, 4), ErrIncorrectPolicy))
+	assert.Equal(t, store.PolicyAny, review.ApprovalPolicy)
+}
+
+func TestPolicyAny(t *testing.T) {
+	review := newPolicyReview(t, store.PolicyAny, 0)
+	require.NoError(t, setVerdict(&review, "first", store.VerdictChangesRequested, nil))
+	assert.False(t, review.Accepted)
+	require.NoError(t, setVerdict(&review, "second", store.VerdictApproved, nil))
+	assert.True(t, review.Accepted)
+	assert.True(t, review.Closed)
+}
+
+func TestPolicyAll(t *testing.T) {
+	review := newPolicyReview(t, store.PolicyAll, 0)
+	require.NoError(t, setVerdict(&review, "first", store.VerdictApproved, nil))
+	require.NoError(t, setVerdict(&review, "second", store.VerdictApproved, nil))
+	assert.False(t, review.Accepted)
+	require.NoError(t, setVerdict(&review, "third", store.VerdictChangesRequested, nil))
+	assert.False(t, review.Accepted)
+	require.NoError(t, setVerdict(&review, "third", store.VerdictApproved, nil))
+	assert.True(t, review.Accepted)
+	assert.Equal(t, 3, len(review.Verdicts))
+}
+
+func TestPolicyCount(t *testing.T) {
+	review := newPolicyReview(t, store.PolicyCount, 2)
+	assert.Equal(t, 2, requiredApprovals(review))
+	require.NoError(t, setVerdict(&review, "first", store.VerdictApproved, nil))
+	assert.False(t, review.Accepted)
+	require.NoError(t, setVerdict(&review, "third", store.VerdictApproved, nil))
+	assert.True(t, review.Accepted)
+
+	// Count is limited by number of reviewers
+	review.Reviewers = review.Reviewers[:1]
+	assert.Equal(t, 1, requiredApprovals(review))
+}
+
+func TestDefaultPolicyCount(t *testing.T) {
+	defer func(policy string, required int) {
+		config.ApprovalPolicy, config.RequiredApprovals = policy, required
+	}(config.ApprovalPolicy, config.RequiredApprovals)
+	config.ApprovalPolicy = string(store.PolicyCount)
+
+	config.RequiredApprovals = 2
+	review := newPolicyReview(t, "", 0)
+	assert.Equal(t, 2, requiredApprovals(review))
+	require.NoError(t, setVerdict(&review, "first", store.VerdictApproved, nil))
+	assert.False(t, review.Accepted)
+
+	// Review is never accepted without approvals
+	config.RequiredApprovals = 0
+	review = newPolicyReview(t, "", 0)
+	assert.Equal(t, 1, requiredApprovals(review))
+	require.NoError(t, setVerdict(&review, "first", store.VerdictChangesRequested, nil))
+	assert.False(t, review.Accepted)
+	assert.Equal(t, store.StateChangesRequested, review.State)
+}
+
+func TestVerdictsResetOnNewRevision(t *testing.T) {
+	review := newPolicyReview(t, store.PolicyAll, 0)
+	require.NoError(t, setVerdict(&review, "first", store.VerdictApproved, nil))
+	require.NoError(t, setVerdict(&review, "second", store.VerdictChangesRequested, nil))
+	assert.Equal(t, map[string]store.VerdictStatus{
+		"first":  store.VerdictApproved,
+		"second": store.VerdictChangesRequested,
+		"third":  store.VerdictPending,
+	}, currentVerdicts(review))
+
+	review.RevisionsCount++
+	assert.Equal(t, map[string]store.VerdictStatus{
+		"first":  store.VerdictPending,
+		"second": store.VerdictPending,
+		"third":  store.VerdictPending,
+	}, currentVerdicts(review))
+	assert.Equal(t, 0, approvalsCount(review))
+	assert.Equal(t, 2, len(review.Verdicts))
+}
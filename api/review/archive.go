@@ -0,0 +1,250 @@
+package review
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dbeliakov/revisor/api/config"
+	"golang.org/x/xerrors"
+)
+
+var (
+	// ErrUnsupportedArchive error
+	ErrUnsupportedArchive = xerrors.New("Unsupported archive format")
+	// ErrArchiveTooLarge error
+	ErrArchiveTooLarge = xerrors.New("Archive is too large")
+	// ErrTooManyFiles error
+	ErrTooManyFiles = xerrors.New("Too many files in archive")
+)
+
+// archiveLimits restricts content of uploaded archives
+type archiveLimits struct {
+	IgnorePatterns []string
+	MaxFiles       int
+	MaxSize        int
+	MaxUnpacked    int
+}
+
+func configArchiveLimits() archiveLimits {
+	return archiveLimits{
+		IgnorePatterns: config.ArchiveIgnorePatterns,
+		MaxFiles:       config.MaxArchiveFiles,
+		MaxSize:        config.MaxArchiveSize,
+		MaxUnpacked:    config.MaxUnpackedSize,
+	}
+}
+
+// isBinary checks content of file in the same way as git does: by looking for NUL byte at the beginning.
+// Files in valid UTF-16 with byte order mark are not binary
+func isBinary(content []byte) bool {
+	const checkLength = 8000
+	if hasUTF16BOM(content) {
+		// Content, which cannot be decoded exactly, is kept as is
+		return !validUTF16(content)
+	}
+	if len(content) > checkLength {
+		content = content[:checkLength]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
+
+// ignored checks if file with specified path matches one of patterns. Pattern without slash is
+// matched against every component of path, otherwise against the whole path
+func ignored(name string, patterns []string) bool {
+	components := strings.Split(name, "/")
+	for _, pattern := range patterns {
+		if strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(strings.Trim(pattern, "/"), name); ok {
+				return true
+			}
+			continue
+		}
+		for _, c := range components {
+			if ok, _ := path.Match(pattern, c); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cleanArchivePath returns normalized relative path of file in archive
+func cleanArchivePath(name string) string {
+	return path.Clean("/" + strings.Replace(name, "\\", "/", -1))[1:]
+}
+
+type archiveReader struct {
+	limits   archiveLimits
+	files    map[string][]byte
+	unpacked int
+}
+
+func (r *archiveReader) add(name string, size int64, content io.Reader) error {
+	name = cleanArchivePath(name)
+	if len(name) == 0 || ignored(name, r.limits.IgnorePatterns) {
+		return nil
+	}
+	if size > int64(r.limits.MaxUnpacked-r.unpacked) {
+		return xerrors.Errorf("file %s: %w", name, ErrArchiveTooLarge)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(content, int64(r.limits.MaxUnpacked-r.unpacked)+1))
+	if err != nil {
+		return xerrors.Errorf("Cannot read file %s from archive: %w", name, err)
+	}
+	r.unpacked += len(data)
+	if r.unpacked > r.limits.MaxUnpacked {
+		return xerrors.Errorf("file %s: %w", name, ErrArchiveTooLarge)
+	}
+	if isBinary(data) {
+		return nil
+	}
+	r.files[name] = data
+	if len(r.files) > r.limits.MaxFiles {
+		return ErrTooManyFiles
+	}
+	return nil
+}
+
+func (r *archiveReader) readZip(data []byte) error {
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return xerrors.Errorf("Cannot open zip archive: %w", err)
+	}
+	for _, f := range z.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return xerrors.Errorf("Cannot open file %s in zip archive: %w", f.Name, err)
+		}
+		err = r.add(f.Name, int64(f.UncompressedSize64), rc)
+		_ = rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *archiveReader) readTarGz(data []byte) error {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return xerrors.Errorf("Cannot open gzip archive: %w", err)
+	}
+	defer func() {
+		_ = gz.Close()
+	}()
+	t := tar.NewReader(gz)
+	for {
+		header, err := t.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return xerrors.Errorf("Cannot read tar archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			continue
+		}
+		err = r.add(header.Name, header.Size, t)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// stripCommonDirectory removes top level directory, if all files are located in it
+func stripCommonDirectory(names []string) []string {
+	if len(names) == 0 {
+		return names
+	}
+	slash := strings.Index(names[0], "/")
+	if slash < 0 {
+		return names
+	}
+	prefix := names[0][:slash+1]
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			return names
+		}
+	}
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		result = append(result, name[len(prefix):])
+	}
+	return result
+}
+
+// unpackArchive extracts text files from zip or tar.gz archive
+func unpackArchive(data []byte, limits archiveLimits) ([]UploadedFile, error) {
+	if len(data) > limits.MaxSize {
+		return nil, ErrArchiveTooLarge
+	}
+	r := archiveReader{
+		limits: limits,
+		files:  make(map[string][]byte),
+	}
+	var err error
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		err = r.readZip(data)
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		err = r.readTarGz(data)
+	default:
+		err = ErrUnsupportedArchive
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(r.files))
+	for name := range r.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make([]UploadedFile, 0, len(names))
+	for i, name := range stripCommonDirectory(names) {
+		result = append(result, newUploadedFile(name, r.files[names[i]]))
+	}
+	return result, nil
+}
+
+// Archive returns zip archive with all files of specified revision of review
+func (files *VersionedFiles) Archive(revision int, blobs BlobLoader) ([]byte, error) {
+	rev, err := files.GetRevision(revision)
+	if err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	z := zip.NewWriter(&buffer)
+	for _, fr := range rev.Files {
+		content, _, err := files.RawFile(revision, fr.Name, blobs)
+		if err != nil {
+			return nil, err
+		}
+		header := &zip.FileHeader{Name: fr.Name, Method: zip.Deflate}
+		if rev.Created > 0 {
+			header.Modified = time.Unix(rev.Created, 0)
+		}
+		w, err := z.CreateHeader(header)
+		if err != nil {
+			return nil, xerrors.Errorf("Cannot add file %s to zip archive: %w", fr.Name, err)
+		}
+		if _, err = w.Write(content); err != nil {
+			return nil, xerrors.Errorf("Cannot write file %s to zip archive: %w", fr.Name, err)
+		}
+	}
+	if err = z.Close(); err != nil {
+		return nil, xerrors.Errorf("Cannot write zip archive: %w", err)
+	}
+	return buffer.Bytes(), nil
+}
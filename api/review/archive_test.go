@@ -0,0 +1,144 @@
+package review
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+)
+
+var (
+	testLimits = archiveLimits{
+		IgnorePatterns: []string{"build", "*.o"},
+		MaxFiles:       3,
+		MaxSize:        1 << 20,
+		MaxUnpacked:    1 << 20,
+	}
+	archiveFiles = map[string]string{
+		"project/main.cpp":       revisions[0],
+		"project/list.h":         "#pragma once\n",
+		"project/list.o":         "object",
+		"project/build/main.cpp": revisions[1],
+		"project/a.out":          "\x7fELF\x00\x00",
+	}
+)
+
+func makeZip(t *testing.T, files map[string]string) []byte {
+	var buffer bytes.Buffer
+	w := zip.NewWriter(&buffer)
+	for name, content := range files {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return buffer.Bytes()
+}
+
+func makeTarGz(t *testing.T, files map[string]string) []byte {
+	var buffer bytes.Buffer
+	gz := gzip.NewWriter(&buffer)
+	w := tar.NewWriter(gz)
+	for name, content := range files {
+		err := w.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		})
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	require.NoError(t, gz.Close())
+	return buffer.Bytes()
+}
+
+func checkUnpacked(t *testing.T, files []UploadedFile) {
+	require.Equal(t, 2, len(files))
+	assert.Equal(t, "list.h", files[0].Name)
+	assert.Equal(t, splitLines("#pragma once\n"), files[0].Content)
+	assert.Equal(t, "main.cpp", files[1].Name)
+}
+
+func TestUnpackZip(t *testing.T) {
+	files, err := unpackArchive(makeZip(t, archiveFiles), testLimits)
+	require.NoError(t, err)
+	checkUnpacked(t, files)
+}
+
+func TestUnpackTarGz(t *testing.T) {
+	files, err := unpackArchive(makeTarGz(t, archiveFiles), testLimits)
+	require.NoError(t, err)
+	checkUnpacked(t, files)
+}
+
+func TestUnpackLimits(t *testing.T) {
+	_, err := unpackArchive([]byte("not an archive"), testLimits)
+	assert.True(t, xerrors.Is(err, ErrUnsupportedArchive))
+
+	limits := testLimits
+	limits.MaxFiles = 1
+	_, err = unpackArchive(makeZip(t, archiveFiles), limits)
+	assert.True(t, xerrors.Is(err, ErrTooManyFiles))
+
+	limits = testLimits
+	limits.MaxUnpacked = len(revisions[0])
+	_, err = unpackArchive(makeTarGz(t, archiveFiles), limits)
+	assert.True(t, xerrors.Is(err, ErrArchiveTooLarge))
+
+	limits = testLimits
+	limits.MaxSize = 10
+	_, err = unpackArchive(makeZip(t, archiveFiles), limits)
+	assert.True(t, xerrors.Is(err, ErrArchiveTooLarge))
+}
+
+func TestIgnoredPatterns(t *testing.T) {
+	assert.True(t, ignored("build/main.cpp", []string{"build"}))
+	assert.True(t, ignored("src/main.o", []string{"*.o"}))
+	assert.True(t, ignored("src/gen/a.cpp", []string{"src/gen/*"}))
+	assert.False(t, ignored("src/main.cpp", []string{"build", "*.o", "gen/*"}))
+	assert.Equal(t, "etc/passwd", cleanArchivePath("../etc/passwd"))
+	assert.Equal(t, "etc/passwd", cleanArchivePath("/etc/passwd"))
+}
+
+func TestRevisionArchive(t *testing.T) {
+	cp1251 := []byte("// \xcf\xf0\xe8\xe2\xe5\xf2\r\n")
+	files, err := NewVersionedFiles([]UploadedFile{
+		newUploadedFile(fileName, []byte(revisions[0])),
+		newUploadedFile("include/"+headerName, cp1251),
+	}, RevisionInfo{Created: 1546300800})
+	require.NoError(t, err)
+	require.NoError(t, files.AddRevision([]UploadedFile{
+		newUploadedFile(fileName, []byte(revisions[1])),
+	}, RevisionInfo{}))
+
+	data, err := files.Archive(0, nil)
+	require.NoError(t, err)
+	unpacked, err := unpackArchive(data, testLimits)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(unpacked))
+	assert.Equal(t, "include/"+headerName, unpacked[0].Name)
+	assert.Equal(t, EncodingCP1251, unpacked[0].Encoding)
+	assert.Equal(t, fileName, unpacked[1].Name)
+
+	content, fr, err := files.RawFile(0, "include/"+headerName, nil)
+	require.NoError(t, err)
+	assert.Equal(t, cp1251, content)
+	assert.Equal(t, LineEndingCRLF, fr.LineEnding)
+	content, _, err = files.RawFile(1, fileName, nil)
+	require.NoError(t, err)
+	assert.Equal(t, revisions[1], string(content))
+
+	_, _, err = files.RawFile(1, "include/"+headerName, nil)
+	assert.True(t, xerrors.Is(err, ErrFileNotFound))
+	_, err = files.Archive(2, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,106 @@
+package review
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/dbeliakov/revisor/api/config"
+	"golang.org/x/xerrors"
+)
+
+var (
+	// ErrFileTooLarge error
+	ErrFileTooLarge = xerrors.New("File is too large")
+	// ErrTooManyLines error
+	ErrTooManyLines = xerrors.New("Too many lines in file")
+	// ErrBinaryPatch error
+	ErrBinaryPatch = xerrors.New("Binary file cannot be patched")
+)
+
+// BinaryContent describes content of binary file. Content itself is stored separately as blob
+type BinaryContent struct {
+	Hash string `json:"hash"` // SHA-256 of content
+	Size int    `json:"size"`
+}
+
+func newBinaryContent(data []byte) *BinaryContent {
+	hash := sha256.Sum256(data)
+	return &BinaryContent{Hash: hex.EncodeToString(hash[:]), Size: len(data)}
+}
+
+// BlobLoader returns content of binary file by its hash
+type BlobLoader func(hash string) ([]byte, error)
+
+// BinaryDiff describes changes of binary file
+type BinaryDiff struct {
+	OldHash string `json:"old_hash,omitempty"`
+	OldSize int    `json:"old_size"`
+	NewHash string `json:"new_hash,omitempty"`
+	NewSize int    `json:"new_size"`
+	Changed bool   `json:"changed"`
+}
+
+// binaryDiff compares revisions of file, if at least one of them is binary. Absent revision is nil
+func binaryDiff(fr1, fr2 *FileRevision) *BinaryDiff {
+	if (fr1 == nil || fr1.Binary == nil) && (fr2 == nil || fr2.Binary == nil) {
+		return nil
+	}
+	result := &BinaryDiff{}
+	if fr1 != nil && fr1.Binary != nil {
+		result.OldHash, result.OldSize = fr1.Binary.Hash, fr1.Binary.Size
+	}
+	if fr2 != nil && fr2.Binary != nil {
+		result.NewHash, result.NewSize = fr2.Binary.Hash, fr2.Binary.Size
+	}
+	result.Changed = result.OldHash != result.NewHash
+	return result
+}
+
+// FileLimitError describes uploaded file, which exceeds limits
+type FileLimitError struct {
+	File  string
+	Limit int
+	Err   error
+}
+
+func (e *FileLimitError) Error() string {
+	return fmt.Sprintf("file %s: %s, limit is %d", e.File, e.Err, e.Limit)
+}
+
+// Unwrap returns ErrFileTooLarge or ErrTooManyLines
+func (e *FileLimitError) Unwrap() error {
+	return e.Err
+}
+
+// fileLimits restricts size of uploaded files
+type fileLimits struct {
+	MaxSize  int
+	MaxLines int
+}
+
+func configFileLimits() fileLimits {
+	return fileLimits{
+		MaxSize:  config.MaxFileSize,
+		MaxLines: config.MaxFileLines,
+	}
+}
+
+// checkFileLimits of uploaded file. Size of text file is measured in its own encoding.
+// Count of lines is not limited for binary files
+func checkFileLimits(f UploadedFile, limits fileLimits) error {
+	size := 0
+	if f.Binary != nil {
+		size = f.Binary.Size
+	} else {
+		size = len(encodeText(strings.Join(f.Content, ""), textFormat(f)))
+	}
+	if size > limits.MaxSize {
+		return &FileLimitError{File: f.Name, Limit: limits.MaxSize, Err: ErrFileTooLarge}
+	}
+	if f.Binary == nil && len(f.Content) > limits.MaxLines {
+		return &FileLimitError{File: f.Name, Limit: limits.MaxLines, Err: ErrTooManyLines}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package review
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dbeliakov/revisor/api/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+)
+
+var (
+	image        = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	changedImage = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00")
+)
+
+// binaryFiles returns review with text file and image, which is changed in the second revision
+func binaryFiles(t *testing.T) (VersionedFiles, map[string][]byte) {
+	blobs := make(map[string][]byte)
+	upload := func(uploaded ...UploadedFile) []UploadedFile {
+		for _, f := range uploaded {
+			if f.Binary != nil {
+				blobs[f.Binary.Hash] = f.Blob
+			}
+		}
+		return uploaded
+	}
+	files, err := NewVersionedFiles(upload(
+		newUploadedFile(fileName, []byte(revisions[0])),
+		newUploadedFile("image.png", image),
+	), RevisionInfo{})
+	require.NoError(t, err)
+	require.NoError(t, files.AddRevision(upload(
+		newUploadedFile(fileName, []byte(revisions[0])),
+		newUploadedFile("image.png", changedImage),
+	), RevisionInfo{}))
+	return files, blobs
+}
+
+func TestBinaryFile(t *testing.T) {
+	files, blobs := binaryFiles(t)
+	fr := files.Revisions[1].Files[1]
+	require.NotNil(t, fr.Binary)
+	assert.Equal(t, len(changedImage), fr.Binary.Size)
+	assert.Equal(t, 64, len(fr.Binary.Hash))
+	assert.Equal(t, TextFormat{}, fr.TextFormat)
+	assert.Equal(t, 0, files.Files[1].RevisionsCount()-1)
+
+	diffs, err := files.Diff(0, 1)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(diffs))
+	assert.Nil(t, diffs[0].Binary)
+	assert.Empty(t, diffs[1].Groups)
+	assert.Equal(t, &BinaryDiff{
+		OldHash: files.Revisions[0].Files[1].Binary.Hash,
+		OldSize: len(image),
+		NewHash: fr.Binary.Hash,
+		NewSize: len(changedImage),
+		Changed: true,
+	}, diffs[1].Binary)
+
+	diffs, err = files.Diff(1, 1)
+	require.NoError(t, err)
+	assert.False(t, diffs[1].Binary.Changed)
+
+	loader := func(hash string) ([]byte, error) {
+		return blobs[hash], nil
+	}
+	content, _, err := files.RawFile(0, "image.png", loader)
+	require.NoError(t, err)
+	assert.Equal(t, image, content)
+}
+
+func TestBinaryPatch(t *testing.T) {
+	files, _ := binaryFiles(t)
+	patch, err := files.Patch(0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "diff --git a/image.png b/image.png\nBinary files a/image.png and b/image.png differ\n", string(patch))
+
+	_, err = files.ApplyPatch(string(patch))
+	var patchErr *PatchError
+	require.True(t, xerrors.As(err, &patchErr))
+	assert.Equal(t, []HunkError{{File: "image.png", Message: ErrBinaryPatch.Error()}}, patchErr.Hunks)
+
+	// Binary files are kept, when text files are patched
+	patched, err := files.ApplyPatch("--- a/main.cpp\n+++ b/main.cpp\n@@ -1 +1 @@\n-" +
+		splitLines(revisions[0])[0] + "+// changed\n")
+	require.NoError(t, err)
+	require.Equal(t, 2, len(patched))
+	assert.Equal(t, files.Revisions[1].Files[1].Binary, patched[1].Binary)
+}
+
+func TestFileLimits(t *testing.T) {
+	limits := fileLimits{MaxSize: 10, MaxLines: 2}
+	assert.NoError(t, checkFileLimits(newUploadedFile(fileName, []byte("a\nb\n")), limits))
+	assert.NoError(t, checkFileLimits(newUploadedFile("image.png", image[:10]), limits))
+
+	err := checkFileLimits(newUploadedFile(fileName, []byte("a\nb\nc\n")), limits)
+	assert.True(t, xerrors.Is(err, ErrTooManyLines))
+	err = checkFileLimits(newUploadedFile("image.png", image), limits)
+	assert.True(t, xerrors.Is(err, ErrFileTooLarge))
+	var limitErr *FileLimitError
+	require.True(t, xerrors.As(err, &limitErr))
+	assert.Equal(t, "image.png", limitErr.File)
+	assert.Equal(t, 10, limitErr.Limit)
+
+	// Size of text is measured in bytes of uploaded file, not of decoded content
+	cp1251 := newUploadedFile(fileName, []byte{0xEF, 0xF0, 0xE8, 0xE2, 0xE5, 0xF2, 0xEC, 0xE8, 0xF0, '\n'})
+	require.Equal(t, EncodingCP1251, cp1251.Encoding)
+	assert.NoError(t, checkFileLimits(cp1251, limits))
+	utf16 := newUploadedFile(fileName, []byte{0xFF, 0xFE, 'a', 0, 'b', 0, 'c', 0, 'd', 0, '\n', 0})
+	require.Equal(t, EncodingUTF16LE, utf16.Encoding)
+	assert.True(t, xerrors.Is(checkFileLimits(utf16, limits), ErrFileTooLarge))
+
+	// Limits from config are checked on upload
+	_, err = NewVersionedFiles([]UploadedFile{
+		newUploadedFile(fileName, []byte(strings.Repeat("\n", config.MaxFileLines+1))),
+	}, RevisionInfo{})
+	assert.True(t, xerrors.Is(err, ErrTooManyLines))
+}
@@ -0,0 +1,108 @@
+package review
+
+import (
+	"github.com/dbeliakov/revisor/api/store"
+	"golang.org/x/xerrors"
+)
+
+var (
+	// ErrNoAccess error
+	ErrNoAccess = xerrors.New("User has no access to review")
+	// ErrUnknownLine error
+	ErrUnknownLine = xerrors.New("No such line in revisions of review")
+	// ErrInvalidRange error
+	ErrInvalidRange = xerrors.New("Lines do not form range in revisions of review")
+)
+
+// hasLine checks if line with specified ID exists in one of revisions of file
+func (file VersionedFile) hasLine(id string) bool {
+	for _, d := range file.deltas {
+		for _, h := range d.Hunks {
+			for _, line := range h.Lines {
+				if line.ID == id {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// HasLine checks if line with specified ID exists in one of revisions of files
+func (files VersionedFiles) HasLine(id string) bool {
+	for _, file := range files.Files {
+		if file.hasLine(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRange checks if both lines exist in the same revision of file and the first line precedes the last one
+func (files *VersionedFiles) hasRange(firstID, lastID string) (bool, error) {
+	found := false
+	err := files.eachFileRevision(func(revision int, fr FileRevision, file File) {
+		first := -1
+		for i, line := range file.Lines {
+			if line.ID == firstID {
+				first = i
+			}
+			if line.ID == lastID && first >= 0 {
+				found = true
+				return
+			}
+		}
+	})
+	return found, err
+}
+
+// checkCommentLines checks that lines exist in revisions of review. Empty line ID means comment
+// on the whole review, empty end line ID means comment on single line
+func checkCommentLines(review store.Review, files VersionedFiles, lineID, endLineID string) error {
+	if len(lineID) > 0 && !files.HasLine(lineID) {
+		return xerrors.Errorf("line %s, review %d: %w", lineID, review.ID, ErrUnknownLine)
+	}
+	if len(endLineID) == 0 {
+		return nil
+	}
+	ok, err := files.hasRange(lineID, endLineID)
+	if err != nil {
+		return xerrors.Errorf("Cannot check range of lines: %w", err)
+	}
+	if !ok {
+		return xerrors.Errorf("lines %s-%s, review %d: %w", lineID, endLineID, review.ID, ErrInvalidRange)
+	}
+	return nil
+}
+
+// checkCommentTarget checks that user participates in review and lines exist in its revisions
+func checkCommentTarget(review store.Review, files VersionedFiles, login, lineID, endLineID string) error {
+	if !hasAccess(login, review) {
+		return xerrors.Errorf("user %s, review %d: %w", login, review.ID, ErrNoAccess)
+	}
+	return checkCommentLines(review, files, lineID, endLineID)
+}
+
+// CommentTarget loads review, which user can comment
+func CommentTarget(reviewID int, login string) (store.Review, error) {
+	review, err := store.Reviews.FindReviewByID(reviewID)
+	if err != nil {
+		return review, err
+	}
+	if !hasAccess(login, review) {
+		return review, xerrors.Errorf("user %s, review %d: %w", login, review.ID, ErrNoAccess)
+	}
+	return review, nil
+}
+
+// CheckCommentLines checks that lines exist in revisions of review. Empty line IDs are not checked
+func CheckCommentLines(review store.Review, lineID, endLineID string) error {
+	if len(lineID) == 0 && len(endLineID) == 0 {
+		return nil
+	}
+	files, err := loadFiles(review)
+	if err != nil {
+		return err
+	}
+	return checkCommentLines(review, files, lineID, endLineID)
+}
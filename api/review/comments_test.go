@@ -0,0 +1,66 @@
+package review
+
+import (
+	"testing"
+
+	"github.com/dbeliakov/revisor/api/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+)
+
+func TestCheckCommentTarget(t *testing.T) {
+	files, err := NewVersionedFiles([]UploadedFile{uploaded(fileName, "a\nb\n")}, RevisionInfo{})
+	require.NoError(t, err)
+	require.NoError(t, files.AddRevision([]UploadedFile{uploaded(fileName, "a\nc\n")}, RevisionInfo{}))
+	first, err := files.Files[0].GetRevision(0)
+	require.NoError(t, err)
+	removed := first.Lines[1].ID
+	last, err := files.Files[0].GetRevision(1)
+	require.NoError(t, err)
+	added := last.Lines[1].ID
+
+	review := store.Review{ID: 1, Owner: "owner", Reviewers: []string{"reviewer"}}
+	assert.NoError(t, checkCommentTarget(review, files, "owner", added, ""))
+	assert.NoError(t, checkCommentTarget(review, files, "reviewer", added, ""))
+	// Line removed in the last revision still can be commented in the first one
+	assert.NoError(t, checkCommentTarget(review, files, "reviewer", removed, ""))
+	assert.NoError(t, checkCommentTarget(review, files, "reviewer", "", ""))
+
+	err = checkCommentTarget(review, files, "stranger", added, "")
+	assert.True(t, xerrors.Is(err, ErrNoAccess))
+	err = checkCommentTarget(review, files, "stranger", "", "")
+	assert.True(t, xerrors.Is(err, ErrNoAccess))
+	err = checkCommentTarget(review, files, "reviewer", "unknown", "")
+	assert.True(t, xerrors.Is(err, ErrUnknownLine))
+	err = checkCommentTarget(review, VersionedFiles{}, "owner", added, "")
+	assert.True(t, xerrors.Is(err, ErrUnknownLine))
+}
+
+func TestCheckCommentRange(t *testing.T) {
+	files, err := NewVersionedFiles([]UploadedFile{
+		uploaded(fileName, "a\nb\nc\n"),
+		uploaded("other.cpp", "d\n"),
+	}, RevisionInfo{})
+	require.NoError(t, err)
+	require.NoError(t, files.AddRevision([]UploadedFile{
+		uploaded(fileName, "a\nc\ne\n"),
+		uploaded("other.cpp", "d\n"),
+	}, RevisionInfo{}))
+	first, err := files.Files[0].GetRevision(0)
+	require.NoError(t, err)
+	last, err := files.Files[0].GetRevision(1)
+	require.NoError(t, err)
+	other, err := files.Files[1].GetRevision(0)
+	require.NoError(t, err)
+	a, b, e := first.Lines[0].ID, first.Lines[1].ID, last.Lines[2].ID
+
+	review := store.Review{ID: 1, Owner: "owner"}
+	assert.NoError(t, checkCommentTarget(review, files, "owner", a, b))
+	assert.NoError(t, checkCommentTarget(review, files, "owner", a, e))
+	// Range of lines is checked within single revision of file
+	for _, lines := range [][2]string{{b, a}, {b, e}, {a, other.Lines[0].ID}, {"", a}, {a, "unknown"}} {
+		err = checkCommentTarget(review, files, "owner", lines[0], lines[1])
+		assert.True(t, xerrors.Is(err, ErrInvalidRange), lines)
+	}
+}
@@ -0,0 +1,168 @@
+package review
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	uuid "github.com/satori/go.uuid"
+)
+
+// deltaLine is a line inserted in revision
+type deltaLine struct {
+	Content  string `json:"c"`
+	Revision int    `json:"r"`
+	ID       string `json:"id"`
+}
+
+// hunk replaces lines [From, To) of previous revision with new lines
+type hunk struct {
+	From  int         `json:"f"`
+	To    int         `json:"t"`
+	Lines []deltaLine `json:"l,omitempty"`
+}
+
+// delta represents changes of revision relative to previous revision
+type delta struct {
+	Hunks []hunk `json:"h"`
+}
+
+// newDelta calculates changes between lines of previous revision and new content.
+// Lines, which are not changed, keep their IDs, new lines are marked with specified revision.
+// Lines are matched by diff algorithm from server config. Moved blocks of lines keep their IDs too
+func newDelta(previous []Line, content []string, revision int) delta {
+	previousContent := make([]string, 0, len(previous))
+	for _, line := range previous {
+		previousContent = append(previousContent, line.Content)
+	}
+
+	result := delta{Hunks: make([]hunk, 0)}
+	codes := defaultAlgorithm().OpCodes(previousContent, content)
+	moves := detectMoves(previous, content, codes)
+	for _, c := range codes {
+		if c.Tag == 'e' {
+			continue
+		}
+		h := hunk{From: c.I1, To: c.I2}
+		for j := c.J1; j < c.J2; j++ {
+			if line, moved := moves[j]; moved {
+				h.Lines = append(h.Lines, deltaLine(line))
+				continue
+			}
+			u := uuid.NewV4()
+			h.Lines = append(h.Lines, deltaLine{Content: content[j], Revision: revision, ID: u.String()})
+		}
+		result.Hunks = append(result.Hunks, h)
+	}
+	return result
+}
+
+// deltaFromLines restores changes between two revisions, which are already split to lines with IDs
+func deltaFromLines(previous, current []Line) delta {
+	// Line with the same ID can be changed by migration of legacy files, so content is compared too
+	previousIDs := make([]string, 0, len(previous))
+	for _, line := range previous {
+		previousIDs = append(previousIDs, line.ID+"\x00"+line.Content)
+	}
+	currentIDs := make([]string, 0, len(current))
+	for _, line := range current {
+		currentIDs = append(currentIDs, line.ID+"\x00"+line.Content)
+	}
+
+	result := delta{Hunks: make([]hunk, 0)}
+	m := difflib.NewMatcher(previousIDs, currentIDs)
+	for _, c := range m.GetOpCodes() {
+		if c.Tag == 'e' {
+			continue
+		}
+		h := hunk{From: c.I1, To: c.I2}
+		for _, line := range current[c.J1:c.J2] {
+			h.Lines = append(h.Lines, deltaLine(line))
+		}
+		result.Hunks = append(result.Hunks, h)
+	}
+	return result
+}
+
+// apply changes to lines of previous revision
+func (d delta) apply(previous []Line) []Line {
+	result := make([]Line, 0, len(previous))
+	pos := 0
+	for _, h := range d.Hunks {
+		result = append(result, previous[pos:h.From]...)
+		for _, line := range h.Lines {
+			result = append(result, Line(line))
+		}
+		pos = h.To
+	}
+	return append(result, previous[pos:]...)
+}
+
+// exactLinesVersion is a version of stored files, since which lines are equal to lines of uploaded
+// files. Previously newline was added to the last line of file on upload
+const exactLinesVersion = 1
+
+// MarshalJSON stores only deltas of revisions
+func (file VersionedFile) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Name    string
+		Version int
+		Deltas  []delta
+	}{
+		Name:    file.Name,
+		Version: exactLinesVersion,
+		Deltas:  file.deltas,
+	})
+}
+
+// UnmarshalJSON supports both current format and legacy formats with full copies of all revisions
+// or with newline added to the last line
+func (file *VersionedFile) UnmarshalJSON(data []byte) error {
+	var stored struct {
+		Name      string
+		Version   int
+		Deltas    []delta
+		Revisions []File
+	}
+	err := json.Unmarshal(data, &stored)
+	if err != nil {
+		return err
+	}
+	file.Name = stored.Name
+	file.deltas = stored.Deltas
+	if stored.Version >= exactLinesVersion {
+		return nil
+	}
+
+	revisions := stored.Revisions
+	if stored.Deltas != nil {
+		revisions = make([]File, 0, len(stored.Deltas))
+		var lines []Line
+		for _, d := range stored.Deltas {
+			lines = d.apply(lines)
+			revisions = append(revisions, File{Lines: lines})
+		}
+	}
+	file.deltas = make([]delta, 0, len(revisions))
+	var previous []Line
+	for _, revision := range revisions {
+		lines := removeAddedNewline(revision.Lines)
+		file.deltas = append(file.deltas, deltaFromLines(previous, lines))
+		previous = lines
+	}
+	return nil
+}
+
+// removeAddedNewline restores lines of legacy file, which last line got newline on upload
+func removeAddedNewline(lines []Line) []Line {
+	if len(lines) == 0 {
+		return lines
+	}
+	result := append([]Line{}, lines...)
+	last := &result[len(result)-1]
+	last.Content = strings.TrimSuffix(last.Content, "\n")
+	if len(last.Content) == 0 {
+		result = result[:len(result)-1]
+	}
+	return result
+}
@@ -0,0 +1,159 @@
+package review
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	benchmarkRevisions = 30
+)
+
+// legacyVersionedFile is a format, in which full copies of all revisions were stored
+type legacyVersionedFile struct {
+	Name      string
+	Revisions []File
+}
+
+func newLegacyVersionedFile(t testing.TB, file VersionedFile) legacyVersionedFile {
+	legacy := legacyVersionedFile{Name: file.Name}
+	for i := 0; i < file.RevisionsCount(); i++ {
+		rev, err := file.GetRevision(i)
+		require.NoError(t, err)
+		legacy.Revisions = append(legacy.Revisions, rev)
+	}
+	return legacy
+}
+
+func manyRevisionsFile(t testing.TB) VersionedFile {
+	file := NewVersionedFile(fileName, splitLines(revisions[0]))
+	for i := 1; i < benchmarkRevisions; i++ {
+		require.NoError(t, file.AddRevision(splitLines(revisions[i%len(revisions)])))
+	}
+	return file
+}
+
+func TestDeltaRoundTrip(t *testing.T) {
+	file := manyRevisionsFile(t)
+	data, err := json.Marshal(&file)
+	require.NoError(t, err)
+	var restored VersionedFile
+	require.NoError(t, json.Unmarshal(data, &restored))
+	assert.Equal(t, newLegacyVersionedFile(t, file), newLegacyVersionedFile(t, restored))
+}
+
+// legacyLinesFile is split to lines in the same way as files uploaded before exactLinesVersion
+func legacyLinesFile(t testing.TB) VersionedFile {
+	file := NewVersionedFile(fileName, difflib.SplitLines(revisions[0]))
+	for i := 1; i < benchmarkRevisions; i++ {
+		require.NoError(t, file.AddRevision(difflib.SplitLines(revisions[i%len(revisions)])))
+	}
+	return file
+}
+
+// checkMigrated checks that migrated file contains exact lines of uploaded files and keeps IDs of lines
+func checkMigrated(t *testing.T, legacy legacyVersionedFile, migrated VersionedFile) {
+	assert.Equal(t, legacy.Name, migrated.Name)
+	require.Equal(t, len(legacy.Revisions), migrated.RevisionsCount())
+	for i, legacyRevision := range legacy.Revisions {
+		rev, err := migrated.GetRevision(i)
+		require.NoError(t, err)
+		assert.Equal(t, splitLines(revisions[i%len(revisions)]), splitContent(rev))
+		for j, line := range rev.Lines {
+			assert.Equal(t, legacyRevision.Lines[j].ID, line.ID)
+		}
+	}
+}
+
+func TestLegacyFormatMigration(t *testing.T) {
+	legacy := newLegacyVersionedFile(t, legacyLinesFile(t))
+	data, err := json.Marshal(&legacy)
+	require.NoError(t, err)
+
+	var migrated VersionedFile
+	require.NoError(t, json.Unmarshal(data, &migrated))
+	checkMigrated(t, legacy, migrated)
+
+	migratedData, err := json.Marshal(&migrated)
+	require.NoError(t, err)
+	assert.True(t, len(migratedData)*2 < len(data), "%d vs %d", len(migratedData), len(data))
+}
+
+func TestLegacyLinesMigration(t *testing.T) {
+	file := legacyLinesFile(t)
+	data, err := json.Marshal(&struct {
+		Name   string
+		Deltas []delta
+	}{Name: file.Name, Deltas: file.deltas})
+	require.NoError(t, err)
+
+	var migrated VersionedFile
+	require.NoError(t, json.Unmarshal(data, &migrated))
+	checkMigrated(t, newLegacyVersionedFile(t, file), migrated)
+
+	// Migrated file is stored in current format and is not changed on next load
+	data, err = json.Marshal(&migrated)
+	require.NoError(t, err)
+	var restored VersionedFile
+	require.NoError(t, json.Unmarshal(data, &restored))
+	assert.Equal(t, migrated.deltas, restored.deltas)
+}
+
+func BenchmarkGetRevision(b *testing.B) {
+	file := manyRevisionsFile(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = file.GetRevision(i % file.RevisionsCount())
+	}
+}
+
+func BenchmarkGetRevisionLegacy(b *testing.B) {
+	legacy := newLegacyVersionedFile(b, manyRevisionsFile(b))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = legacy.Revisions[i%len(legacy.Revisions)]
+	}
+}
+
+func BenchmarkDiff(b *testing.B) {
+	file := manyRevisionsFile(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = file.Diff(0, file.RevisionsCount()-1)
+	}
+}
+
+func BenchmarkDiffLegacy(b *testing.B) {
+	legacy := newLegacyVersionedFile(b, manyRevisionsFile(b))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = diffFiles(legacy.Name, legacy.Revisions[0], legacy.Revisions[len(legacy.Revisions)-1], DiffOptions{})
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	file := manyRevisionsFile(b)
+	data, err := json.Marshal(&file)
+	require.NoError(b, err)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var restored VersionedFile
+		_ = json.Unmarshal(data, &restored)
+	}
+}
+
+func BenchmarkUnmarshalLegacy(b *testing.B) {
+	data, err := json.Marshal(newLegacyVersionedFile(b, manyRevisionsFile(b)))
+	require.NoError(b, err)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var restored legacyVersionedFile
+		_ = json.Unmarshal(data, &restored)
+	}
+}
@@ -0,0 +1,234 @@
+package review
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encodings of uploaded files. Content of files is always stored in UTF-8 and converted back on download
+const (
+	EncodingUTF8    = "utf-8"
+	EncodingUTF16LE = "utf-16le"
+	EncodingUTF16BE = "utf-16be"
+	EncodingCP1251  = "windows-1251"
+)
+
+// Line endings of files
+const (
+	LineEndingLF    = "lf"
+	LineEndingCRLF  = "crlf"
+	LineEndingMixed = "mixed"
+)
+
+var (
+	bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+	bomUTF16LE = []byte{0xff, 0xfe}
+	bomUTF16BE = []byte{0xfe, 0xff}
+)
+
+// cp1251 maps bytes 0x80-0xbf of Windows-1251 to runes. Bytes 0xc0-0xff are mapped to
+// cyrillic letters А-я, which are placed sequentially. Undefined byte 0x98 is mapped to
+// control character with the same code to keep conversion reversible
+var cp1251 = [64]rune{
+	'Ђ', 'Ѓ', '‚', 'ѓ', '„', '…', '†', '‡', '€', '‰', 'Љ', '‹', 'Њ', 'Ќ', 'Ћ', 'Џ',
+	'ђ', '‘', '’', '“', '”', '•', '–', '—', '\u0098', '™', 'љ', '›', 'њ', 'ќ', 'ћ', 'џ',
+	'\u00a0', 'Ў', 'ў', 'Ј', '¤', 'Ґ', '¦', '§', 'Ё', '©', 'Є', '«', '¬', '\u00ad', '®', 'Ї',
+	'°', '±', 'І', 'і', 'ґ', 'µ', '¶', '·', 'ё', '№', 'є', '»', 'ј', 'Ѕ', 'ѕ', 'ї',
+}
+
+// TextFormat describes how content of file was encoded in uploaded file
+type TextFormat struct {
+	// Encoding is empty for files uploaded before encodings detection, these files are in UTF-8
+	Encoding     string `json:",omitempty"`
+	BOM          bool   `json:",omitempty"`
+	LineEnding   string `json:",omitempty"`
+	FinalNewline bool   `json:",omitempty"`
+}
+
+// hasUTF16BOM checks if content starts with byte order mark of UTF-16
+func hasUTF16BOM(data []byte) bool {
+	return bytes.HasPrefix(data, bomUTF16LE) || bytes.HasPrefix(data, bomUTF16BE)
+}
+
+// validUTF16 checks that content after byte order mark consists of complete code units without
+// unpaired surrogates, so it is decoded and encoded back exactly
+func validUTF16(data []byte) bool {
+	var order binary.ByteOrder = binary.LittleEndian
+	if bytes.HasPrefix(data, bomUTF16BE) {
+		order = binary.BigEndian
+	} else if !bytes.HasPrefix(data, bomUTF16LE) {
+		return false
+	}
+	data = data[len(bomUTF16LE):]
+	if len(data)%2 != 0 {
+		return false
+	}
+	for i := 0; i < len(data); i += 2 {
+		u := order.Uint16(data[i:])
+		switch {
+		case u >= 0xd800 && u < 0xdc00:
+			// High surrogate must be followed by low surrogate
+			if i+3 >= len(data) {
+				return false
+			}
+			if next := order.Uint16(data[i+2:]); next < 0xdc00 || next >= 0xe000 {
+				return false
+			}
+			i += 2
+		case u >= 0xdc00 && u < 0xe000:
+			return false
+		}
+	}
+	return true
+}
+
+// decodeText detects encoding of content and converts it to UTF-8. UTF-16 is detected only by
+// byte order mark, content, which is not valid UTF-8 or UTF-16, is considered to be in Windows-1251
+func decodeText(data []byte) (string, TextFormat) {
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		return string(data[len(bomUTF8):]), TextFormat{Encoding: EncodingUTF8, BOM: true}
+	case bytes.HasPrefix(data, bomUTF16LE) && validUTF16(data):
+		return decodeUTF16(data[len(bomUTF16LE):], binary.LittleEndian), TextFormat{Encoding: EncodingUTF16LE, BOM: true}
+	case bytes.HasPrefix(data, bomUTF16BE) && validUTF16(data):
+		return decodeUTF16(data[len(bomUTF16BE):], binary.BigEndian), TextFormat{Encoding: EncodingUTF16BE, BOM: true}
+	case utf8.Valid(data):
+		return string(data), TextFormat{Encoding: EncodingUTF8}
+	}
+	var builder strings.Builder
+	builder.Grow(len(data) * 2)
+	for _, b := range data {
+		switch {
+		case b < 0x80:
+			builder.WriteByte(b)
+		case b < 0xc0:
+			builder.WriteRune(cp1251[b-0x80])
+		default:
+			builder.WriteRune('А' + rune(b-0xc0))
+		}
+	}
+	return builder.String(), TextFormat{Encoding: EncodingCP1251}
+}
+
+// decodeUTF16 content, which is checked by validUTF16
+func decodeUTF16(data []byte, order binary.ByteOrder) string {
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		units = append(units, order.Uint16(data[i:]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// encodeText converts content back to encoding of uploaded file. Runes, which cannot be
+// represented in Windows-1251, are replaced with question mark
+func encodeText(content string, format TextFormat) []byte {
+	var result []byte
+	switch format.Encoding {
+	case EncodingUTF16LE, EncodingUTF16BE:
+		var order binary.ByteOrder = binary.LittleEndian
+		bom := bomUTF16LE
+		if format.Encoding == EncodingUTF16BE {
+			order, bom = binary.BigEndian, bomUTF16BE
+		}
+		units := utf16.Encode([]rune(content))
+		result = make([]byte, 0, len(bom)+2*len(units))
+		if format.BOM {
+			result = append(result, bom...)
+		}
+		for _, u := range units {
+			result = append(result, 0, 0)
+			order.PutUint16(result[len(result)-2:], u)
+		}
+	case EncodingCP1251:
+		result = make([]byte, 0, len(content))
+		for _, r := range content {
+			result = append(result, encodeCP1251(r))
+		}
+	default:
+		if format.BOM {
+			result = append(result, bomUTF8...)
+		}
+		result = append(result, content...)
+	}
+	return result
+}
+
+func encodeCP1251(r rune) byte {
+	switch {
+	case r < 0x80:
+		return byte(r)
+	case r >= 'А' && r <= 'я':
+		return byte(r-'А') + 0xc0
+	}
+	for i, c := range cp1251 {
+		if c == r {
+			return byte(i + 0x80)
+		}
+	}
+	return '?'
+}
+
+// splitLines splits content to lines, which keep their line endings. Unlike difflib.SplitLines,
+// newline is not added to the last line, so joined lines are equal to the original content
+func splitLines(content string) []string {
+	lines := strings.SplitAfter(content, "\n")
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lineEnding detects line endings used in lines of file. Empty string is returned for files without line breaks
+func lineEnding(lines []string) string {
+	result := ""
+	for _, line := range lines {
+		if !strings.HasSuffix(line, "\n") {
+			continue
+		}
+		current := LineEndingLF
+		if strings.HasSuffix(line, "\r\n") {
+			current = LineEndingCRLF
+		}
+		if len(result) > 0 && result != current {
+			return LineEndingMixed
+		}
+		result = current
+	}
+	return result
+}
+
+// textFormat of uploaded file. Format of binary file is empty
+func textFormat(f UploadedFile) TextFormat {
+	if f.Binary != nil {
+		return TextFormat{}
+	}
+	format := TextFormat{
+		Encoding:   f.Encoding,
+		BOM:        f.BOM,
+		LineEnding: lineEnding(f.Content),
+	}
+	if len(format.Encoding) == 0 {
+		format.Encoding = EncodingUTF8
+	}
+	if len(f.Content) > 0 {
+		format.FinalNewline = strings.HasSuffix(f.Content[len(f.Content)-1], "\n")
+	}
+	return format
+}
+
+// newUploadedFile decodes content of uploaded file and splits it to lines. Content of binary file is kept as is
+func newUploadedFile(name string, data []byte) UploadedFile {
+	if isBinary(data) {
+		return UploadedFile{Name: name, Content: []string{}, Binary: newBinaryContent(data), Blob: data}
+	}
+	content, format := decodeText(data)
+	return UploadedFile{
+		Name:     name,
+		Content:  splitLines(content),
+		Encoding: format.Encoding,
+		BOM:      format.BOM,
+	}
+}
@@ -0,0 +1,102 @@
+package review
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeText(t *testing.T) {
+	tests := []struct {
+		data     []byte
+		content  string
+		encoding string
+		bom      bool
+	}{
+		{[]byte("int a;\n"), "int a;\n", EncodingUTF8, false},
+		{[]byte("\xef\xbb\xbfпривет"), "привет", EncodingUTF8, true},
+		{[]byte("// \xcf\xf0\xe8\xe2\xe5\xf2, \xb8\xe6\xe8\xea \xb9\x88"), "// Привет, ёжик №€", EncodingCP1251, false},
+		{[]byte{0xff, 0xfe, 'a', 0, 0x3f, 0x04, '\n', 0}, "aп\n", EncodingUTF16LE, true},
+		{[]byte{0xfe, 0xff, 0, 'a', 0x04, 0x3f, 0, '\n'}, "aп\n", EncodingUTF16BE, true},
+	}
+	for _, test := range tests {
+		content, format := decodeText(test.data)
+		assert.Equal(t, test.content, content)
+		assert.Equal(t, test.encoding, format.Encoding)
+		assert.Equal(t, test.bom, format.BOM)
+		assert.Equal(t, test.data, encodeText(content, format))
+	}
+}
+
+func TestCP1251RoundTrip(t *testing.T) {
+	data := make([]byte, 0, 256)
+	for b := 0; b < 256; b++ {
+		data = append(data, byte(b))
+	}
+	content, format := decodeText(data)
+	require.Equal(t, EncodingCP1251, format.Encoding)
+	assert.Equal(t, data, encodeText(content, format))
+	assert.Equal(t, []byte("a?"), encodeText("a中", format))
+}
+
+func TestExactLines(t *testing.T) {
+	for _, content := range []string{"", "\n", "a", "a\n", "\n\na\r\nb\n\n\n", "  a\n b"} {
+		uploaded := newUploadedFile(fileName, []byte(content))
+		assert.Equal(t, content, strings.Join(uploaded.Content, ""))
+
+		file := NewVersionedFile(fileName, uploaded.Content)
+		rev, err := file.GetRevision(0)
+		require.NoError(t, err)
+		assert.Equal(t, content, rev.Content())
+		assert.Equal(t, len(rev.Lines), len(contentDiff(fileName, rev).Groups[0].Lines))
+	}
+}
+
+func TestTextFormat(t *testing.T) {
+	files, err := NewVersionedFiles([]UploadedFile{
+		newUploadedFile("lf.cpp", []byte("a\nb\n")),
+		newUploadedFile("crlf.cpp", []byte("a\r\nb")),
+		newUploadedFile("mixed.cpp", []byte("\xef\xbb\xbfa\r\nb\n")),
+		newUploadedFile("single.cpp", []byte("\xe0")),
+	}, RevisionInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, []TextFormat{
+		{Encoding: EncodingUTF8, LineEnding: LineEndingLF, FinalNewline: true},
+		{Encoding: EncodingUTF8, LineEnding: LineEndingCRLF},
+		{Encoding: EncodingUTF8, BOM: true, LineEnding: LineEndingMixed, FinalNewline: true},
+		{Encoding: EncodingCP1251},
+	}, []TextFormat{
+		files.Revisions[0].Files[0].TextFormat,
+		files.Revisions[0].Files[1].TextFormat,
+		files.Revisions[0].Files[2].TextFormat,
+		files.Revisions[0].Files[3].TextFormat,
+	})
+
+	// Same content in other encoding does not create new revision of file, but changes its format
+	require.NoError(t, files.AddRevision([]UploadedFile{newUploadedFile("single.cpp", []byte("а"))}, RevisionInfo{}))
+	fr := files.Revisions[1].Files[0]
+	assert.Equal(t, 0, fr.Revision)
+	assert.Equal(t, EncodingUTF8, fr.Encoding)
+}
+
+func TestInvalidUTF16(t *testing.T) {
+	for _, data := range [][]byte{
+		{0xff, 0xfe, 'a', 0, '\n'},
+		{0xfe, 0xff, 0, 'a', 0},
+		{0xff, 0xfe, 0x00, 0xd8, 'a', 0},
+		{0xff, 0xfe, 0x00, 0xdc},
+	} {
+		uploaded := newUploadedFile(fileName, data)
+		require.NotNil(t, uploaded.Binary)
+		assert.Equal(t, data, uploaded.Blob)
+
+		// Content is not truncated, when it is decoded as text
+		content, format := decodeText(data)
+		assert.NotEqual(t, EncodingUTF16LE, format.Encoding)
+		assert.NotEqual(t, EncodingUTF16BE, format.Encoding)
+		assert.Equal(t, data, encodeText(content, format))
+	}
+	assert.Nil(t, newUploadedFile(fileName, []byte{0xff, 0xfe, 0x3d, 0xd8, 0x00, 0xde}).Binary)
+}
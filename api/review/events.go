@@ -0,0 +1,118 @@
+package review
+
+import (
+	"time"
+
+	"github.com/dbeliakov/revisor/api/store"
+	"github.com/sirupsen/logrus"
+)
+
+// reviewEvents returns events for changes between old and updated versions of review.
+// Empty old review means, that review was created
+func reviewEvents(old, updated store.Review, actor string) []store.Event {
+	now := time.Now().Unix()
+	events := make([]store.Event, 0)
+	if old.ID == 0 {
+		events = append(events, store.Event{Type: store.EventCreated, Actor: actor, Created: now})
+	} else if updated.RevisionsCount > old.RevisionsCount {
+		events = append(events, store.Event{
+			Type:     store.EventRevisionUploaded,
+			Actor:    actor,
+			Created:  now,
+			Revision: updated.RevisionsCount - 1,
+		})
+	}
+
+	if old.ID != 0 {
+		for _, reviewer := range difference(updated.Reviewers, old.Reviewers) {
+			events = append(events, store.Event{Type: store.EventReviewerAdded, Actor: actor, Created: now, Reviewer: reviewer})
+		}
+		for _, reviewer := range difference(old.Reviewers, updated.Reviewers) {
+			events = append(events, store.Event{Type: store.EventReviewerRemoved, Actor: actor, Created: now, Reviewer: reviewer})
+		}
+	}
+
+	for _, verdict := range updated.Verdicts {
+		if containsVerdict(old.Verdicts, verdict) {
+			continue
+		}
+		events = append(events, store.Event{
+			Type:     store.EventVerdict,
+			Actor:    verdict.Reviewer,
+			Created:  verdict.Created,
+			Revision: verdict.Revision,
+			Verdict:  verdict.Status,
+		})
+	}
+	if len(updated.Transitions) > len(old.Transitions) {
+		for _, t := range updated.Transitions[len(old.Transitions):] {
+			events = append(events, store.Event{Type: store.EventStateChanged, Actor: t.Actor, Created: t.Created, State: t.To})
+		}
+	}
+	return events
+}
+
+// difference returns elements of a, which are absent in b
+func difference(a, b []string) []string {
+	exists := make(map[string]bool)
+	for _, s := range b {
+		exists[s] = true
+	}
+	result := make([]string, 0)
+	for _, s := range a {
+		if !exists[s] {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func containsVerdict(verdicts []store.Verdict, verdict store.Verdict) bool {
+	for _, v := range verdicts {
+		if v == verdict {
+			return true
+		}
+	}
+	return false
+}
+
+// saveEvents of review. Action on review is already saved, so errors are only logged
+func saveEvents(reviewID int, events []store.Event) {
+	for i := range events {
+		err := store.Events.AddEvent(reviewID, &events[i])
+		if err != nil {
+			logrus.Errorf("Cannot save event for review %d: %+v", reviewID, err)
+		}
+	}
+}
+
+// APIEvent represents api result struct
+type APIEvent struct {
+	ID        int                 `json:"id"`
+	Type      store.EventType     `json:"type"`
+	Actor     string              `json:"actor"`
+	Created   int64               `json:"created"`
+	Revision  int                 `json:"revision"`
+	Reviewer  string              `json:"reviewer,omitempty"`
+	CommentID int                 `json:"comment_id,omitempty"`
+	Verdict   store.VerdictStatus `json:"verdict,omitempty"`
+	State     store.ReviewState   `json:"state,omitempty"`
+}
+
+func newAPIEvents(events []store.Event) []APIEvent {
+	result := make([]APIEvent, 0, len(events))
+	for _, e := range events {
+		result = append(result, APIEvent{
+			ID:        e.ID,
+			Type:      e.Type,
+			Actor:     e.Actor,
+			Created:   e.Created,
+			Revision:  e.Revision,
+			Reviewer:  e.Reviewer,
+			CommentID: e.CommentID,
+			Verdict:   e.Verdict,
+			State:     e.State,
+		})
+	}
+	return result
+}
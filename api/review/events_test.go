@@ -0,0 +1,61 @@
+package review
+
+import (
+	"testing"
+
+	"github.com/dbeliakov/revisor/api/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func eventTypes(events []store.Event) []store.EventType {
+	result := make([]store.EventType, 0, len(events))
+	for _, e := range events {
+		result = append(result, e.Type)
+	}
+	return result
+}
+
+func TestCreatedEvent(t *testing.T) {
+	review := newPolicyReview(t, store.PolicyAny, 0)
+	review.ID = 1
+	events := reviewEvents(store.Review{}, review, "owner")
+	require.Equal(t, 1, len(events))
+	assert.Equal(t, store.EventCreated, events[0].Type)
+	assert.Equal(t, "owner", events[0].Actor)
+	assert.NotZero(t, events[0].Created)
+}
+
+func TestUpdateEvents(t *testing.T) {
+	old := newPolicyReview(t, store.PolicyAny, 0)
+	old.ID = 1
+	updated := old
+	updated.RevisionsCount = 2
+	updated.Reviewers = []string{"first", "third", "fourth"}
+
+	events := reviewEvents(old, updated, "owner")
+	assert.Equal(t, []store.EventType{
+		store.EventRevisionUploaded,
+		store.EventReviewerAdded,
+		store.EventReviewerRemoved,
+	}, eventTypes(events))
+	assert.Equal(t, 1, events[0].Revision)
+	assert.Equal(t, "fourth", events[1].Reviewer)
+	assert.Equal(t, "second", events[2].Reviewer)
+
+	assert.Empty(t, reviewEvents(updated, updated, "owner"))
+}
+
+func TestVerdictEvents(t *testing.T) {
+	old := newPolicyReview(t, store.PolicyAny, 0)
+	old.ID = 1
+	require.NoError(t, setVerdict(&old, "first", store.VerdictChangesRequested, nil))
+
+	updated := old
+	require.NoError(t, setVerdict(&updated, "second", store.VerdictApproved, nil))
+	events := reviewEvents(old, updated, "second")
+	assert.Equal(t, []store.EventType{store.EventVerdict, store.EventStateChanged}, eventTypes(events))
+	assert.Equal(t, "second", events[0].Actor)
+	assert.Equal(t, store.VerdictApproved, events[0].Verdict)
+	assert.Equal(t, store.StateAccepted, events[1].State)
+}
@@ -0,0 +1,393 @@
+package review
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"golang.org/x/xerrors"
+)
+
+// UploadedFile represents content of file uploaded as part of revision
+type UploadedFile struct {
+	Name    string
+	OldName string // Name of file in previous revision, if it was renamed
+	Content []string
+	// Encoding of uploaded file, UTF-8 is used if it is empty
+	Encoding string
+	BOM      bool
+	// Binary file has no lines. Its content is kept in Blob until it is saved to storage
+	Binary *BinaryContent
+	Blob   []byte
+}
+
+// FileRevision points to revision of versioned file in revision of review
+type FileRevision struct {
+	File     int
+	Revision int
+	Name     string
+	TextFormat
+	Binary *BinaryContent `json:",omitempty"`
+}
+
+// RevisionInfo contains metadata of revision of review
+type RevisionInfo struct {
+	Created     int64
+	Author      string
+	Description string
+}
+
+// Revision represents set of files in revision of review
+type Revision struct {
+	Files []FileRevision
+	RevisionInfo
+}
+
+// VersionedFiles represents all files of review with all their revisions
+type VersionedFiles struct {
+	Files     []VersionedFile
+	Revisions []Revision
+}
+
+var (
+	// ErrDuplicateFileName error
+	ErrDuplicateFileName = xerrors.New("Duplicate file name")
+	// ErrEmptyFileName error
+	ErrEmptyFileName = xerrors.New("Empty file name")
+	// ErrNoFiles error
+	ErrNoFiles = xerrors.New("No files in revision")
+	// ErrUnknownFile error
+	ErrUnknownFile = xerrors.New("No such file in previous revision")
+	// ErrFileNotFound error
+	ErrFileNotFound = xerrors.New("No such file in revision")
+)
+
+func checkUploadedFiles(files []UploadedFile) error {
+	if len(files) == 0 {
+		return ErrNoFiles
+	}
+	names := make(map[string]bool)
+	oldNames := make(map[string]bool)
+	limits := configFileLimits()
+	for _, f := range files {
+		if len(f.Name) == 0 {
+			return ErrEmptyFileName
+		}
+		if err := checkFileLimits(f, limits); err != nil {
+			return err
+		}
+		if names[f.Name] {
+			return xerrors.Errorf("file %s: %w", f.Name, ErrDuplicateFileName)
+		}
+		names[f.Name] = true
+		if len(f.OldName) > 0 {
+			if oldNames[f.OldName] {
+				return xerrors.Errorf("file %s: %w", f.OldName, ErrDuplicateFileName)
+			}
+			oldNames[f.OldName] = true
+		}
+	}
+	// File without old name continues file with the same name, which cannot be renamed at the same time
+	for _, f := range files {
+		if len(f.OldName) == 0 && oldNames[f.Name] {
+			return xerrors.Errorf("file %s: %w", f.Name, ErrDuplicateFileName)
+		}
+	}
+	return nil
+}
+
+// NewVersionedFiles constructs versioned files from content of original files
+func NewVersionedFiles(files []UploadedFile, info RevisionInfo) (VersionedFiles, error) {
+	if err := checkUploadedFiles(files); err != nil {
+		return VersionedFiles{}, err
+	}
+	result := VersionedFiles{
+		Files:     make([]VersionedFile, 0, len(files)),
+		Revisions: []Revision{{Files: make([]FileRevision, 0, len(files)), RevisionInfo: info}},
+	}
+	for i, f := range files {
+		result.Files = append(result.Files, NewVersionedFile(f.Name, f.Content))
+		result.Revisions[0].Files = append(result.Revisions[0].Files, FileRevision{
+			File:       i,
+			Revision:   0,
+			Name:       f.Name,
+			TextFormat: textFormat(f),
+			Binary:     f.Binary,
+		})
+	}
+	return result, nil
+}
+
+// RevisionsCount returns count of revisions of review (includes original files)
+func (files VersionedFiles) RevisionsCount() int {
+	return len(files.Revisions)
+}
+
+// GetRevision returns specified revision of review
+func (files *VersionedFiles) GetRevision(revision int) (Revision, error) {
+	if revision >= len(files.Revisions) || revision < 0 {
+		return Revision{}, fmt.Errorf(
+			"Bad revision: expected from %d to %d, got %d", 0, len(files.Revisions)-1, revision)
+	}
+	return files.Revisions[revision], nil
+}
+
+func sameContent(file File, content []string) bool {
+	if len(file.Lines) != len(content) {
+		return false
+	}
+	for i, line := range file.Lines {
+		if line.Content != content[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// AddRevision adds new revision, which consists of specified files. Files of previous revision,
+// which are not present in new revision, are considered to be removed
+func (files *VersionedFiles) AddRevision(uploaded []UploadedFile, info RevisionInfo) error {
+	if err := checkUploadedFiles(uploaded); err != nil {
+		return err
+	}
+	revision := files.RevisionsCount()
+	lastRevision, _ := files.GetRevision(revision - 1)
+	previous := make(map[string]FileRevision)
+	for _, fr := range lastRevision.Files {
+		previous[fr.Name] = fr
+	}
+
+	// Find files of previous revision before changing anything, so that failed revision leaves files intact
+	type source struct {
+		fr   FileRevision
+		last File
+	}
+	sources := make([]*source, len(uploaded))
+	for i, f := range uploaded {
+		oldName := f.Name
+		if len(f.OldName) > 0 {
+			oldName = f.OldName
+		}
+		fr, exists := previous[oldName]
+		if !exists {
+			if len(f.OldName) > 0 {
+				return xerrors.Errorf("file %s: %w", f.OldName, ErrUnknownFile)
+			}
+			continue
+		}
+		last, err := files.Files[fr.File].GetRevision(fr.Revision)
+		if err != nil {
+			return err
+		}
+		sources[i] = &source{fr: fr, last: last}
+	}
+
+	newRevision := Revision{Files: make([]FileRevision, 0, len(uploaded)), RevisionInfo: info}
+	for i, f := range uploaded {
+		if sources[i] == nil {
+			files.Files = append(files.Files, newVersionedFile(f.Name, f.Content, revision))
+			newRevision.Files = append(newRevision.Files, FileRevision{
+				File:       len(files.Files) - 1,
+				Revision:   0,
+				Name:       f.Name,
+				TextFormat: textFormat(f),
+				Binary:     f.Binary,
+			})
+			continue
+		}
+
+		fr := sources[i].fr
+		file := &files.Files[fr.File]
+		if !sameContent(sources[i].last, f.Content) {
+			file.deltas = append(file.deltas, newDelta(sources[i].last.Lines, f.Content, revision))
+			fr.Revision = file.RevisionsCount() - 1
+		}
+		fr.Name = f.Name
+		// Content can be the same, but encoding or byte order mark can be changed
+		fr.TextFormat = textFormat(f)
+		fr.Binary = f.Binary
+		newRevision.Files = append(newRevision.Files, fr)
+	}
+	files.Revisions = append(files.Revisions, newRevision)
+	return nil
+}
+
+// Diff returns diffs of all files between two revisions of review. Files added in second revision
+// are compared with empty file, removed files are compared with empty file too
+func (files *VersionedFiles) Diff(revision1, revision2 int) ([]Diff, error) {
+	return files.DiffWithOptions(revision1, revision2, DiffOptions{})
+}
+
+// DiffWithOptions returns diffs of all files between two revisions, which ignore differences specified by options
+func (files *VersionedFiles) DiffWithOptions(revision1, revision2 int, options DiffOptions) ([]Diff, error) {
+	rev1, err := files.GetRevision(revision1)
+	if err != nil {
+		return nil, err
+	}
+	rev2, err := files.GetRevision(revision2)
+	if err != nil {
+		return nil, err
+	}
+	oldFiles := make(map[int]FileRevision)
+	for _, fr := range rev1.Files {
+		oldFiles[fr.File] = fr
+	}
+
+	result := make([]Diff, 0, len(rev2.Files))
+	for _, fr2 := range rev2.Files {
+		file := &files.Files[fr2.File]
+		file2, err := file.GetRevision(fr2.Revision)
+		if err != nil {
+			return nil, err
+		}
+		fr1, exists := oldFiles[fr2.File]
+		if !exists {
+			diff := diffFiles(fr2.Name, File{}, file2, options)
+			diff.Added = true
+			result = append(result, withBinary(diff, nil, &fr2))
+			continue
+		}
+		delete(oldFiles, fr2.File)
+		var diff Diff
+		if revision1 != revision2 && fr1.Revision == fr2.Revision {
+			// File is not changed between revisions of review, so all its lines are collapsed
+			diff = diffFiles(fr2.Name, file2, file2, options)
+		} else {
+			diff, err = file.DiffWithOptions(fr1.Revision, fr2.Revision, options)
+			if err != nil {
+				return nil, err
+			}
+		}
+		diff.FileName = fr2.Name
+		if fr1.Name != fr2.Name {
+			diff.OldFileName = fr1.Name
+		}
+		result = append(result, withBinary(diff, &fr1, &fr2))
+	}
+	for _, fr1 := range rev1.Files {
+		if _, removed := oldFiles[fr1.File]; !removed {
+			continue
+		}
+		file1, err := files.Files[fr1.File].GetRevision(fr1.Revision)
+		if err != nil {
+			return nil, err
+		}
+		diff := diffFiles(fr1.Name, file1, File{}, options)
+		diff.Removed = true
+		result = append(result, withBinary(diff, &fr1, nil))
+	}
+	return result, nil
+}
+
+// withBinary replaces lines of diff with description of changes, if one of revisions of file is binary
+func withBinary(diff Diff, fr1, fr2 *FileRevision) Diff {
+	if diff.Binary = binaryDiff(fr1, fr2); diff.Binary != nil {
+		diff.Groups = []DiffGroup{}
+	}
+	return diff
+}
+
+// UnmarshalJSON supports both current format and legacy format, in which review had only one file
+func (files *VersionedFiles) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(data, &fields)
+	if err != nil {
+		return err
+	}
+	if _, ok := fields["Files"]; ok {
+		type Alias VersionedFiles
+		return json.Unmarshal(data, (*Alias)(files))
+	}
+
+	var legacy VersionedFile
+	err = json.Unmarshal(data, &legacy)
+	if err != nil {
+		return err
+	}
+	files.Files = []VersionedFile{legacy}
+	files.Revisions = make([]Revision, 0, legacy.RevisionsCount())
+	for i := 0; i < legacy.RevisionsCount(); i++ {
+		files.Revisions = append(files.Revisions, Revision{
+			Files: []FileRevision{{File: 0, Revision: i, Name: legacy.Name}},
+		})
+	}
+	return nil
+}
+
+// fileRevisions returns revisions of file with specified name in two revisions of review. File is
+// searched in the second revision and in the first one, if it was removed. Absent revision is nil
+func (files *VersionedFiles) fileRevisions(revision1, revision2 int, name string) (*FileRevision, *FileRevision, error) {
+	rev1, err := files.GetRevision(revision1)
+	if err != nil {
+		return nil, nil, err
+	}
+	rev2, err := files.GetRevision(revision2)
+	if err != nil {
+		return nil, nil, err
+	}
+	var fr1, fr2 *FileRevision
+	for i := range rev2.Files {
+		if rev2.Files[i].Name == name {
+			fr2 = &rev2.Files[i]
+		}
+	}
+	for i := range rev1.Files {
+		if (fr2 != nil && rev1.Files[i].File == fr2.File) || (fr2 == nil && rev1.Files[i].Name == name) {
+			fr1 = &rev1.Files[i]
+		}
+	}
+	if fr1 == nil && fr2 == nil {
+		return nil, nil, xerrors.Errorf("file %s: %w", name, ErrUnknownFile)
+	}
+	return fr1, fr2, nil
+}
+
+// content of file revision. Absent file is empty
+func (files *VersionedFiles) content(fr *FileRevision) (File, error) {
+	if fr == nil {
+		return File{}, nil
+	}
+	return files.Files[fr.File].GetRevision(fr.Revision)
+}
+
+// HiddenLines returns lines of diff of file, which are placed in specified ranges of the file in two
+// revisions. It is used to expand lines, which were collapsed between groups of diff
+func (files *VersionedFiles) HiddenLines(revision1, revision2 int, name string,
+	oldRange, newRange diffRange, options DiffOptions) (DiffGroup, error) {
+	fr1, fr2, err := files.fileRevisions(revision1, revision2, name)
+	if err != nil {
+		return DiffGroup{}, err
+	}
+	file1, err := files.content(fr1)
+	if err != nil {
+		return DiffGroup{}, err
+	}
+	file2, err := files.content(fr2)
+	if err != nil {
+		return DiffGroup{}, err
+	}
+	return buildDiff(file1, file2, options).hidden(oldRange, newRange), nil
+}
+
+// RawFile returns content of file in specified revision of review. Content of text file is encoded
+// in the same way as uploaded file, content of binary file is loaded by blobs
+func (files *VersionedFiles) RawFile(revision int, name string, blobs BlobLoader) ([]byte, FileRevision, error) {
+	rev, err := files.GetRevision(revision)
+	if err != nil {
+		return nil, FileRevision{}, err
+	}
+	for _, fr := range rev.Files {
+		if fr.Name != name {
+			continue
+		}
+		if fr.Binary != nil {
+			content, err := blobs(fr.Binary.Hash)
+			return content, fr, err
+		}
+		file, err := files.Files[fr.File].GetRevision(fr.Revision)
+		if err != nil {
+			return nil, FileRevision{}, err
+		}
+		return encodeText(file.Content(), fr.TextFormat), fr, nil
+	}
+	return nil, FileRevision{}, xerrors.Errorf("file %s: %w", name, ErrFileNotFound)
+}
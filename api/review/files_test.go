@@ -0,0 +1,153 @@
+package review
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+)
+
+const (
+	headerName = "list.h"
+	otherName  = "list.cpp"
+)
+
+func uploaded(name, content string) UploadedFile {
+	return UploadedFile{Name: name, Content: splitLines(content)}
+}
+
+func TestNewVersionedFilesIncorrect(t *testing.T) {
+	_, err := NewVersionedFiles(nil, RevisionInfo{})
+	assert.True(t, xerrors.Is(err, ErrNoFiles))
+	_, err = NewVersionedFiles([]UploadedFile{uploaded("", revisions[0])}, RevisionInfo{})
+	assert.True(t, xerrors.Is(err, ErrEmptyFileName))
+	_, err = NewVersionedFiles([]UploadedFile{uploaded(fileName, revisions[0]), uploaded(fileName, revisions[1])}, RevisionInfo{})
+	assert.True(t, xerrors.Is(err, ErrDuplicateFileName))
+}
+
+func TestFilesRevisions(t *testing.T) {
+	files, err := NewVersionedFiles([]UploadedFile{uploaded(fileName, revisions[0]), uploaded(headerName, "")}, RevisionInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, files.RevisionsCount())
+
+	// Change main file, keep header
+	err = files.AddRevision([]UploadedFile{uploaded(fileName, revisions[1]), uploaded(headerName, "")}, RevisionInfo{})
+	require.NoError(t, err)
+	// Remove header, rename main file and add new file
+	renamed := uploaded(otherName, revisions[2])
+	renamed.OldName = fileName
+	err = files.AddRevision([]UploadedFile{renamed, uploaded(headerName+".new", revisions[0])}, RevisionInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, files.RevisionsCount())
+	assert.Equal(t, 3, len(files.Files))
+	assert.Equal(t, 3, files.Files[0].RevisionsCount())
+	assert.Equal(t, 1, files.Files[1].RevisionsCount())
+
+	rev, err := files.GetRevision(2)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(rev.Files))
+	format := TextFormat{Encoding: EncodingUTF8, LineEnding: LineEndingLF, FinalNewline: true}
+	assert.Equal(t, FileRevision{File: 0, Revision: 2, Name: otherName, TextFormat: format}, rev.Files[0])
+	assert.Equal(t, FileRevision{File: 2, Revision: 0, Name: headerName + ".new", TextFormat: format}, rev.Files[1])
+
+	file, err := files.Files[2].GetRevision(0)
+	require.NoError(t, err)
+	for _, line := range file.Lines {
+		assert.Equal(t, 2, line.Revision)
+	}
+
+	_, err = files.GetRevision(3)
+	assert.Error(t, err)
+}
+
+func TestFilesUnknownRename(t *testing.T) {
+	files, err := NewVersionedFiles([]UploadedFile{uploaded(fileName, revisions[0])}, RevisionInfo{})
+	require.NoError(t, err)
+	renamed := uploaded(otherName, revisions[1])
+	renamed.OldName = headerName
+	err = files.AddRevision([]UploadedFile{renamed}, RevisionInfo{})
+	assert.True(t, xerrors.Is(err, ErrUnknownFile))
+	assert.Equal(t, 1, files.RevisionsCount())
+
+	// Failed revision does not add files
+	err = files.AddRevision([]UploadedFile{uploaded(headerName+".new", revisions[0]), renamed}, RevisionInfo{})
+	assert.True(t, xerrors.Is(err, ErrUnknownFile))
+	assert.Equal(t, 1, files.RevisionsCount())
+	assert.Equal(t, 1, len(files.Files))
+}
+
+func TestFilesRenameAndKeep(t *testing.T) {
+	files, err := NewVersionedFiles([]UploadedFile{uploaded(fileName, revisions[0])}, RevisionInfo{})
+	require.NoError(t, err)
+	renamed := uploaded(otherName, revisions[1])
+	renamed.OldName = fileName
+	err = files.AddRevision([]UploadedFile{renamed, uploaded(fileName, revisions[2])}, RevisionInfo{})
+	assert.True(t, xerrors.Is(err, ErrDuplicateFileName))
+	err = files.AddRevision([]UploadedFile{uploaded(fileName, revisions[2]), renamed}, RevisionInfo{})
+	assert.True(t, xerrors.Is(err, ErrDuplicateFileName))
+	assert.Equal(t, 1, files.RevisionsCount())
+	assert.Equal(t, 1, files.Files[0].RevisionsCount())
+}
+
+func TestFilesDiff(t *testing.T) {
+	files, err := NewVersionedFiles([]UploadedFile{uploaded(fileName, revisions[0]), uploaded(headerName, "int f();\n")}, RevisionInfo{})
+	require.NoError(t, err)
+	renamed := uploaded(otherName, revisions[1])
+	renamed.OldName = fileName
+	err = files.AddRevision([]UploadedFile{renamed, uploaded(headerName+".new", "int g();\n")}, RevisionInfo{})
+	require.NoError(t, err)
+
+	diffs, err := files.Diff(0, 1)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(diffs))
+
+	assert.Equal(t, otherName, diffs[0].FileName)
+	assert.Equal(t, fileName, diffs[0].OldFileName)
+	expected := strings.Replace(readFile("second-first.diff"), "+++ "+fileName, "+++ "+otherName, 1)
+	assert.Equal(t, strings.TrimSpace(expected), strings.TrimSpace(diffs[0].String()))
+
+	assert.Equal(t, "--- list.h.new\n+++ list.h.new\n@@ -0,0 +1 @@\n+int g();\n", diffs[1].String())
+	assert.Equal(t, "--- list.h\n+++ list.h\n@@ -1 +0,0 @@\n-int f();\n", diffs[2].String())
+}
+
+func TestLegacyVersionedFile(t *testing.T) {
+	file := NewVersionedFile(fileName, splitLines(revisions[0]))
+	require.NoError(t, file.AddRevision(splitLines(revisions[1])))
+	data, err := json.Marshal(&file)
+	require.NoError(t, err)
+
+	var files VersionedFiles
+	require.NoError(t, json.Unmarshal(data, &files))
+	assert.Equal(t, 2, files.RevisionsCount())
+	diffs, err := files.Diff(0, 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(diffs))
+	assert.Equal(t, strings.TrimSpace(readFile("second-first.diff")), strings.TrimSpace(diffs[0].String()))
+
+	data, err = json.Marshal(&files)
+	require.NoError(t, err)
+	var restored VersionedFiles
+	require.NoError(t, json.Unmarshal(data, &restored))
+	assert.Equal(t, files, restored)
+}
+
+func TestRevisionInfo(t *testing.T) {
+	first := RevisionInfo{Created: 1, Author: "owner"}
+	files, err := NewVersionedFiles([]UploadedFile{uploaded(fileName, revisions[0])}, first)
+	require.NoError(t, err)
+	second := RevisionInfo{Created: 2, Author: "owner", Description: "Fix review comments"}
+	require.NoError(t, files.AddRevision([]UploadedFile{uploaded(fileName, revisions[1])}, second))
+
+	data, err := json.Marshal(&files)
+	require.NoError(t, err)
+	var restored VersionedFiles
+	require.NoError(t, json.Unmarshal(data, &restored))
+	for i, info := range []RevisionInfo{first, second} {
+		rev, err := restored.GetRevision(i)
+		require.NoError(t, err)
+		assert.Equal(t, info, rev.RevisionInfo)
+	}
+}
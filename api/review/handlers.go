@@ -3,7 +3,9 @@ package review
 import (
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"path"
 	"strconv"
 	"strings"
 	"time"
@@ -12,9 +14,8 @@ import (
 	"github.com/dbeliakov/revisor/api/store"
 	"github.com/dbeliakov/revisor/api/utils"
 	"github.com/gorilla/mux"
-	"github.com/pmezard/go-difflib/difflib"
 	"github.com/sirupsen/logrus"
-	"github.com/valyala/fastjson"
+	"golang.org/x/xerrors"
 )
 
 func hasAccess(login string, review store.Review) bool {
@@ -31,15 +32,99 @@ func hasAccess(login string, review store.Review) bool {
 
 // APIReview represents api result struct
 type APIReview struct {
-	ID             int            `json:"id"`
-	Name           string         `json:"name"`
-	Updated        int64          `json:"updated"`
-	Closed         bool           `json:"closed"`
-	Accepted       bool           `json:"accepted"`
-	Owner          auth.APIUser   `json:"owner"`
-	Reviewers      []auth.APIUser `json:"reviewers"`
-	RevisionsCount int            `json:"revisions_count"`
-	CommentsCount  int            `json:"comments_count"`
+	ID             int               `json:"id"`
+	Name           string            `json:"name"`
+	Updated        int64             `json:"updated"`
+	State          store.ReviewState `json:"state"`
+	Closed         bool              `json:"closed"`
+	Accepted       bool              `json:"accepted"`
+	Owner          auth.APIUser      `json:"owner"`
+	Reviewers      []auth.APIUser    `json:"reviewers"`
+	RevisionsCount int               `json:"revisions_count"`
+	CommentsCount  int               `json:"comments_count"`
+	// UnresolvedCount is a count of unresolved threads of comments
+	UnresolvedCount int  `json:"unresolved_count"`
+	BlockUnresolved bool `json:"block_unresolved"`
+	// Verdicts of reviewers for the last revision
+	Verdicts          []APIVerdict         `json:"verdicts"`
+	ApprovalPolicy    store.ApprovalPolicy `json:"approval_policy"`
+	RequiredApprovals int                  `json:"required_approvals"`
+	Approvals         int                  `json:"approvals"`
+}
+
+// APITransition represents api result struct
+type APITransition struct {
+	From    store.ReviewState `json:"from"`
+	To      store.ReviewState `json:"to"`
+	Actor   string            `json:"actor"`
+	Created int64             `json:"created"`
+}
+
+func newAPITransitions(review store.Review) []APITransition {
+	result := make([]APITransition, 0, len(review.Transitions))
+	for _, t := range review.Transitions {
+		result = append(result, APITransition{From: t.From, To: t.To, Actor: t.Actor, Created: t.Created})
+	}
+	return result
+}
+
+// APIRevisionFile represents api result struct
+type APIRevisionFile struct {
+	Name         string         `json:"name"`
+	Encoding     string         `json:"encoding"`
+	BOM          bool           `json:"bom"`
+	LineEnding   string         `json:"line_ending"`
+	FinalNewline bool           `json:"final_newline"`
+	Binary       *BinaryContent `json:"binary,omitempty"`
+}
+
+// APIRevision represents api result struct
+type APIRevision struct {
+	Number      int               `json:"number"`
+	Created     int64             `json:"created"`
+	Author      string            `json:"author"`
+	Description string            `json:"description"`
+	Files       []APIRevisionFile `json:"files"`
+}
+
+func newAPIRevisions(review store.Review, files VersionedFiles) []APIRevision {
+	result := make([]APIRevision, 0, len(files.Revisions))
+	for i, rev := range files.Revisions {
+		revision := APIRevision{
+			Number:      i,
+			Created:     rev.Created,
+			Author:      rev.Author,
+			Description: rev.Description,
+			Files:       make([]APIRevisionFile, 0, len(rev.Files)),
+		}
+		for _, fr := range rev.Files {
+			file := APIRevisionFile{
+				Name:         fr.Name,
+				Encoding:     fr.Encoding,
+				BOM:          fr.BOM,
+				LineEnding:   fr.LineEnding,
+				FinalNewline: fr.FinalNewline,
+				Binary:       fr.Binary,
+			}
+			// Files uploaded before encodings detection are in UTF-8, line endings are unknown
+			if len(file.Encoding) == 0 && file.Binary == nil {
+				file.Encoding = EncodingUTF8
+			}
+			revision.Files = append(revision.Files, file)
+		}
+		// Revisions uploaded before metadata was introduced could be uploaded only by owner
+		if len(revision.Author) == 0 {
+			revision.Author = review.Owner
+		}
+		result = append(result, revision)
+	}
+	return result
+}
+
+// APIVerdict represents api result struct
+type APIVerdict struct {
+	Reviewer string              `json:"reviewer"`
+	Status   store.VerdictStatus `json:"status"`
 }
 
 // NewAPIReview creates new api review from store review
@@ -48,6 +133,7 @@ func NewAPIReview(review store.Review) (APIReview, error) {
 		ID:       review.ID,
 		Name:     review.Name,
 		Updated:  review.Updated,
+		State:    reviewState(review),
 		Closed:   review.Closed,
 		Accepted: review.Accepted,
 	}
@@ -64,17 +150,27 @@ func NewAPIReview(review store.Review) (APIReview, error) {
 		}
 		result.Reviewers = append(result.Reviewers, auth.NewAPIUser(reviewer))
 	}
-	var p fastjson.Parser
-	v, err := p.ParseBytes(review.File)
-	if err != nil {
-		return result, err
+	verdicts := currentVerdicts(review)
+	result.Verdicts = make([]APIVerdict, 0, len(review.Reviewers))
+	for _, login := range review.Reviewers {
+		result.Verdicts = append(result.Verdicts, APIVerdict{Reviewer: login, Status: verdicts[login]})
 	}
-	result.RevisionsCount = len(v.GetArray("Revisions"))
+	result.ApprovalPolicy = approvalPolicy(review)
+	result.RequiredApprovals = requiredApprovals(review)
+	result.Approvals = approvalsCount(review)
+	result.RevisionsCount = review.RevisionsCount
 	comments, err := store.Comments.CommentsForReview(review.ID)
 	if err != nil {
 		return result, err
 	}
-	result.CommentsCount = len(comments)
+	for _, comment := range comments {
+		// Tombstones of deleted comments are not counted
+		if !comment.Deleted {
+			result.CommentsCount++
+		}
+	}
+	result.UnresolvedCount = unresolvedThreads(comments)
+	result.BlockUnresolved = review.BlockUnresolved
 	return result, nil
 }
 
@@ -90,14 +186,447 @@ func newAPIReviews(reviews []store.Review) ([]APIReview, error) {
 	return result, nil
 }
 
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// APIReviewsPage represents api result struct
+type APIReviewsPage struct {
+	Reviews    []APIReview `json:"reviews"`
+	Total      int         `json:"total"`
+	NextCursor string      `json:"next_cursor"`
+}
+
+func newAPIReviewsPage(page store.ReviewsPage) (APIReviewsPage, error) {
+	reviews, err := newAPIReviews(page.Reviews)
+	if err != nil {
+		return APIReviewsPage{}, err
+	}
+	return APIReviewsPage{
+		Reviews:    reviews,
+		Total:      page.Total,
+		NextCursor: page.NextCursor,
+	}, nil
+}
+
+// parseReviewsQuery from url parameters. If error occurs, writes error message to response writer
+func parseReviewsQuery(w http.ResponseWriter, r *http.Request) (store.ReviewsQuery, error) {
+	params := r.URL.Query()
+	query := store.ReviewsQuery{
+		Status:     store.ReviewStatus(params.Get("status")),
+		Name:       params.Get("name"),
+		Owner:      params.Get("owner"),
+		Sort:       store.SortByUpdated,
+		Descending: params.Get("order") != "asc",
+		Cursor:     params.Get("cursor"),
+		Limit:      defaultPageSize,
+	}
+	if len(params.Get("sort")) > 0 {
+		query.Sort = store.ReviewsSort(params.Get("sort"))
+	}
+
+	var err error
+	if len(params.Get("updated_since")) > 0 {
+		query.UpdatedSince, err = strconv.ParseInt(params.Get("updated_since"), 10, 64)
+	}
+	if err == nil && len(params.Get("limit")) > 0 {
+		query.Limit, err = strconv.Atoi(params.Get("limit"))
+		if err == nil && (query.Limit <= 0 || query.Limit > maxPageSize) {
+			err = xerrors.Errorf("limit %d: %w", query.Limit, store.ErrIncorrectQuery)
+		}
+	}
+	if err != nil {
+		logrus.Warnf("Incorrect reviews query: %+v", err)
+		incorrectReviewsQuery(w)
+		return query, err
+	}
+	return query, nil
+}
+
+func incorrectReviewsQuery(w http.ResponseWriter) {
+	utils.Error(w, utils.JSONErrorResponse{
+		Status:        http.StatusBadRequest,
+		Message:       "Incorrect reviews query",
+		ClientMessage: "Некорректные параметры поиска ревью",
+	})
+}
+
+// intParamOr returns value of integer url parameter or default value, if parameter is absent
+func intParamOr(r *http.Request, param string, def int) (int, error) {
+	value := r.URL.Query()[param]
+	if len(value) > 0 {
+		return strconv.Atoi(value[0])
+	}
+	return def, nil
+}
+
+// parseRevisions to compare from url parameters. If error occurs, writes error message to response writer
+func parseRevisions(w http.ResponseWriter, r *http.Request, files VersionedFiles) (int, int, error) {
+	startRev, err := intParamOr(r, "start_rev", 0)
+	if err != nil {
+		logrus.Warnf("Incorrect start revision: %+v", err)
+		utils.Error(w, utils.JSONErrorResponse{
+			Status:        http.StatusBadRequest,
+			Message:       "Incorrect start revision",
+			ClientMessage: "Некорректный номер начальной ревизии",
+		})
+		return 0, 0, err
+	}
+	endRev, err := intParamOr(r, "end_rev", files.RevisionsCount()-1)
+	if err != nil {
+		logrus.Warnf("Incorrect end revision: %+v", err)
+		utils.Error(w, utils.JSONErrorResponse{
+			Status:        http.StatusBadRequest,
+			Message:       "Incorrect end revision",
+			ClientMessage: "Некорректный номер конечной ревизии",
+		})
+		return 0, 0, err
+	}
+	return startRev, endRev, nil
+}
+
+// parseDiffOptions from url parameters. If error occurs, writes error message to response writer
+func parseDiffOptions(w http.ResponseWriter, r *http.Request) (DiffOptions, error) {
+	var options DiffOptions
+	params := r.URL.Query()
+	flags := map[string]*bool{
+		"ignore_all_space":    &options.IgnoreAllSpace,
+		"ignore_space_change": &options.IgnoreSpaceChange,
+		"ignore_blank_lines":  &options.IgnoreBlankLines,
+		"ignore_line_endings": &options.IgnoreLineEndings,
+	}
+	for name, flag := range flags {
+		if len(params.Get(name)) == 0 {
+			continue
+		}
+		value, err := strconv.ParseBool(params.Get(name))
+		if err != nil {
+			logrus.Warnf("Incorrect diff option %s: %+v", name, err)
+			utils.Error(w, utils.JSONErrorResponse{
+				Status:        http.StatusBadRequest,
+				Message:       "Incorrect diff option: " + name,
+				ClientMessage: "Некорректные параметры сравнения ревизий",
+			})
+			return options, err
+		}
+		*flag = value
+	}
+	if len(params.Get("context")) > 0 {
+		context, err := strconv.Atoi(params.Get("context"))
+		if err != nil || context < 0 {
+			logrus.Warnf("Incorrect context: %s, error: %+v", params.Get("context"), err)
+			utils.Error(w, utils.JSONErrorResponse{
+				Status:        http.StatusBadRequest,
+				Message:       "Incorrect context",
+				ClientMessage: "Некорректные параметры сравнения ревизий",
+			})
+			return options, utils.ErrIncorectFormFields
+		}
+		options.Collapse = true
+		options.Context = context
+	}
+	algorithm, err := algorithmByName(params.Get("algorithm"))
+	if err != nil {
+		logrus.Warnf("Incorrect diff algorithm: %+v", err)
+		utils.Error(w, utils.JSONErrorResponse{
+			Status:        http.StatusBadRequest,
+			Message:       "Unknown diff algorithm",
+			ClientMessage: "Неизвестный алгоритм сравнения ревизий",
+		})
+		return options, err
+	}
+	options.Algorithm = algorithm
+	return options, nil
+}
+
+// loadFiles of review from storage
+func loadFiles(review store.Review) (VersionedFiles, error) {
+	var files VersionedFiles
+	stored, err := store.Files.FindFileByID(review.FileID)
+	if err != nil {
+		return files, err
+	}
+	err = json.Unmarshal(stored.Content, &files)
+	if err != nil {
+		return files, xerrors.Errorf("Cannot deserialize versioned files: %w", err)
+	}
+	return files, nil
+}
+
+// requestedReview loads review specified in url, if user has access to it. If error occurs, writes
+// error message to response writer
+func requestedReview(w http.ResponseWriter, r *http.Request, login string) (store.Review, error) {
+	vars := mux.Vars(r)
+	reviewID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		logrus.Warnf("Incorrect ID: %s, error: %+v", vars["id"], err)
+		utils.Error(w, utils.JSONErrorResponse{
+			Status:        http.StatusNotFound,
+			Message:       "No review with id: " + vars["id"],
+			ClientMessage: "Не удалось найти ревью",
+		})
+		return store.Review{}, err
+	}
+	review, err := store.Reviews.FindReviewByID(reviewID)
+	if err != nil {
+		logrus.Warnf("Cannot find review: %d, error: %+v", reviewID, err)
+		utils.Error(w, utils.JSONErrorResponse{
+			Status:        http.StatusNotFound,
+			Message:       "No review with id: " + vars["id"],
+			ClientMessage: "Не удалось найти ревью",
+		})
+		return review, err
+	}
+	if !hasAccess(login, review) {
+		logrus.Warnf("User %s has no access to review %d", login, review.ID)
+		utils.Error(w, utils.JSONErrorResponse{
+			Status:        http.StatusForbidden,
+			Message:       "No access to this review",
+			ClientMessage: "У вас недостаточно прав для просмотра ревью",
+		})
+		return review, xerrors.Errorf("user %s, review %d: %w", login, review.ID, ErrNoAccess)
+	}
+	return review, nil
+}
+
+// accessibleReview loads review specified in url and its files, if authorized user has access to it.
+// If error occurs, writes error message to response writer
+func accessibleReview(w http.ResponseWriter, r *http.Request) (store.Review, VersionedFiles, error) {
+	var files VersionedFiles
+	user, err := auth.UserFromRequest(r)
+	if err != nil {
+		logrus.Errorf("Error while getting user from request context: %+v", err)
+		utils.Error(w, utils.InternalErrorResponse("No authorized user for this request"))
+		return store.Review{}, files, err
+	}
+	review, err := requestedReview(w, r, user.Login)
+	if err != nil {
+		return review, files, err
+	}
+	files, err = loadFiles(review)
+	if err != nil {
+		logrus.Errorf("Cannot load versioned files: %+v", err)
+		utils.Error(w, utils.InternalErrorResponse("Cannot load versioned files"))
+		return review, files, err
+	}
+	return review, files, nil
+}
+
+// fileForm represents file in request of review creation or update
+type fileForm struct {
+	Name    string `json:"name" validate:"required"`
+	OldName string `json:"old_name"`
+	Content string `json:"content"`
+}
+
+// decodeFiles from request forms. If error occurs, writes error message to response writer
+func decodeFiles(w http.ResponseWriter, forms []fileForm) ([]UploadedFile, error) {
+	files := make([]UploadedFile, 0, len(forms))
+	for _, f := range forms {
+		content, err := base64.StdEncoding.DecodeString(f.Content)
+		if err != nil {
+			logrus.Warnf("Incorrect base64 file content: %s, error: %+v", f.Content, err)
+			utils.Error(w, utils.JSONErrorResponse{
+				Status:        http.StatusBadRequest,
+				Message:       "Incorrect base64 file content",
+				ClientMessage: "Некорректная кодировка файла",
+			})
+			return nil, err
+		}
+		uploaded := newUploadedFile(f.Name, content)
+		uploaded.OldName = f.OldName
+		files = append(files, uploaded)
+	}
+	return files, nil
+}
+
+// decodeArchive from request form. If error occurs, writes error message to response writer
+func decodeArchive(w http.ResponseWriter, archive string) ([]UploadedFile, error) {
+	data, err := base64.StdEncoding.DecodeString(archive)
+	if err != nil {
+		logrus.Warnf("Incorrect base64 archive content, error: %+v", err)
+		utils.Error(w, utils.JSONErrorResponse{
+			Status:        http.StatusBadRequest,
+			Message:       "Incorrect base64 archive content",
+			ClientMessage: "Некорректная кодировка архива",
+		})
+		return nil, err
+	}
+	files, err := unpackArchive(data, configArchiveLimits())
+	if err != nil {
+		logrus.Warnf("Cannot unpack archive: %+v", err)
+		response := utils.JSONErrorResponse{
+			Status:        http.StatusBadRequest,
+			Message:       "Cannot unpack archive",
+			ClientMessage: "Не удалось распаковать архив",
+		}
+		if xerrors.Is(err, ErrUnsupportedArchive) {
+			response.Message = "Unsupported archive format"
+			response.ClientMessage = "Поддерживаются только архивы zip и tar.gz"
+		} else if xerrors.Is(err, ErrArchiveTooLarge) {
+			response.Status = http.StatusRequestEntityTooLarge
+			response.Message = "Archive is too large"
+			response.ClientMessage = "Слишком большой архив"
+		} else if xerrors.Is(err, ErrTooManyFiles) {
+			response.Status = http.StatusRequestEntityTooLarge
+			response.Message = "Too many files in archive"
+			response.ClientMessage = "Слишком много файлов в архиве"
+		}
+		utils.Error(w, response)
+		return nil, err
+	}
+	return files, nil
+}
+
+// uploadedFiles from request form, which contains either list of files or archive.
+// If error occurs, writes error message to response writer
+func uploadedFiles(w http.ResponseWriter, forms []fileForm, archive string) ([]UploadedFile, error) {
+	if len(archive) == 0 {
+		return decodeFiles(w, forms)
+	}
+	if len(forms) > 0 {
+		logrus.Warnf("Both files and archive are specified")
+		utils.Error(w, utils.JSONErrorResponse{
+			Status:        http.StatusNotAcceptable,
+			Message:       "Both files and archive are specified",
+			ClientMessage: "Необходимо загрузить либо файлы, либо архив",
+		})
+		return nil, utils.ErrIncorectFormFields
+	}
+	return decodeArchive(w, archive)
+}
+
+// incorrectPolicy writes error message about incorrect approval policy to response writer
+func incorrectPolicy(w http.ResponseWriter, err error) {
+	logrus.Warnf("Incorrect approval policy: %+v", err)
+	utils.Error(w, utils.JSONErrorResponse{
+		Status:        http.StatusNotAcceptable,
+		Message:       "Incorrect approval policy",
+		ClientMessage: "Некорректные условия принятия ревью",
+	})
+}
+
+// incorrectTransition writes error message about incorrect transition of review state to response writer
+func incorrectTransition(w http.ResponseWriter, err error) {
+	logrus.Warnf("Incorrect transition: %+v", err)
+	utils.Error(w, utils.JSONErrorResponse{
+		Status:        http.StatusConflict,
+		Message:       "Incorrect transition of review state",
+		ClientMessage: "Действие недоступно в текущем состоянии ревью",
+	})
+}
+
+// patchedFiles returns files of new revision, which is created by applying patch to the last revision.
+// If error occurs, writes error message to response writer
+func patchedFiles(w http.ResponseWriter, files *VersionedFiles, patch string) ([]UploadedFile, error) {
+	data, err := base64.StdEncoding.DecodeString(patch)
+	if err != nil {
+		logrus.Warnf("Incorrect base64 patch content, error: %+v", err)
+		utils.Error(w, utils.JSONErrorResponse{
+			Status:        http.StatusBadRequest,
+			Message:       "Incorrect base64 patch content",
+			ClientMessage: "Некорректная кодировка патча",
+		})
+		return nil, err
+	}
+	// Patch is converted to UTF-8 in the same way as uploaded files
+	content, _ := decodeText(data)
+	uploaded, err := files.ApplyPatch(content)
+	var patchErr *PatchError
+	if xerrors.As(err, &patchErr) {
+		logrus.Warnf("Cannot apply patch: %+v", err)
+		utils.Error(w, utils.JSONErrorResponse{
+			Status:        http.StatusConflict,
+			Message:       "Patch does not apply",
+			ClientMessage: "Не удалось применить патч к последней ревизии",
+			Details:       patchErr.Hunks,
+		})
+		return nil, err
+	} else if err != nil {
+		logrus.Warnf("Incorrect patch: %+v", err)
+		utils.Error(w, utils.JSONErrorResponse{
+			Status:        http.StatusBadRequest,
+			Message:       "Incorrect patch",
+			ClientMessage: "Некорректный формат патча",
+		})
+		return nil, err
+	}
+	return uploaded, nil
+}
+
+// incorrectFiles writes error message about incorrect set of files to response writer
+func incorrectFiles(w http.ResponseWriter, err error) {
+	logrus.Warnf("Incorrect set of files: %+v", err)
+	var limitErr *FileLimitError
+	if xerrors.As(err, &limitErr) {
+		response := utils.JSONErrorResponse{
+			Status:        http.StatusRequestEntityTooLarge,
+			Message:       limitErr.Error(),
+			ClientMessage: fmt.Sprintf("Файл %s больше допустимого размера: %d байт", limitErr.File, limitErr.Limit),
+		}
+		if xerrors.Is(err, ErrTooManyLines) {
+			response.ClientMessage = fmt.Sprintf("В файле %s больше %d строк", limitErr.File, limitErr.Limit)
+		}
+		utils.Error(w, response)
+		return
+	}
+	utils.Error(w, utils.JSONErrorResponse{
+		Status:        http.StatusNotAcceptable,
+		Message:       "Incorrect set of files",
+		ClientMessage: "Некорректный набор файлов",
+	})
+}
+
+// uploadedBlobs returns content of uploaded binary files, which should be saved with review
+func uploadedBlobs(uploaded []UploadedFile) []store.Blob {
+	blobs := make([]store.Blob, 0)
+	for _, f := range uploaded {
+		if f.Binary != nil && f.Blob != nil {
+			blobs = append(blobs, store.Blob{Hash: f.Binary.Hash, Content: f.Blob})
+		}
+	}
+	return blobs
+}
+
+// loadBlob with content of binary file from storage
+func loadBlob(hash string) ([]byte, error) {
+	blob, err := store.Blobs.FindBlobByHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	return blob.Content, nil
+}
+
 // APIComment represents api result struct
 type APIComment struct {
-	ID      int           `json:"id"`
-	Author  auth.APIUser  `json:"author"`
-	Created int64         `json:"created"`
-	Text    string        `json:"text"`
-	LineID  string        `json:"line_id"`
-	Childs  []*APIComment `json:"childs"`
+	ID      int          `json:"id"`
+	Author  auth.APIUser `json:"author"`
+	Created int64        `json:"created"`
+	Text    string       `json:"text"`
+	LineID  string       `json:"line_id"`
+	// Last line of commented range
+	EndLineID string        `json:"end_line_id,omitempty"`
+	Childs    []*APIComment `json:"childs"`
+	Updated   int64         `json:"updated,omitempty"`
+	Edits     []APIEdit     `json:"edits"`
+	Deleted   bool          `json:"deleted,omitempty"`
+	// Resolution of thread, is set for root comments only
+	Resolved   bool   `json:"resolved"`
+	ResolvedBy string `json:"resolved_by,omitempty"`
+	ResolvedAt int64  `json:"resolved_at,omitempty"`
+	// Revisions of review, in which commented line was introduced and removed
+	IntroducedRevision int  `json:"introduced_revision"`
+	RemovedRevision    *int `json:"removed_revision,omitempty"`
+	// Outdated comment has no line in compared revisions and is returned with context of line
+	Outdated bool               `json:"outdated"`
+	Context  *APICommentContext `json:"context,omitempty"`
+}
+
+// APIEdit represents api result struct
+type APIEdit struct {
+	Text   string `json:"text"`
+	Edited int64  `json:"edited"`
 }
 
 // NewAPIComment creates new api comment from store comment
@@ -108,6 +637,18 @@ func NewAPIComment(comment store.Comment) (APIComment, error) {
 		Text:    comment.Text,
 		LineID:  comment.LineID,
 		Childs:  make([]*APIComment, 0),
+
+		EndLineID: comment.EndLineID,
+		Updated:   comment.Updated,
+		Edits:     make([]APIEdit, 0, len(comment.Edits)),
+		Deleted:   comment.Deleted,
+
+		Resolved:   comment.Resolved,
+		ResolvedBy: comment.ResolvedBy,
+		ResolvedAt: comment.ResolvedAt,
+	}
+	for _, edit := range comment.Edits {
+		result.Edits = append(result.Edits, APIEdit(edit))
 	}
 	author, err := store.Auth.FindUserByLogin(comment.Author)
 	if err != nil {
@@ -126,13 +667,21 @@ var OutgoingReviews = auth.Required(func(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	reviews, err := store.Reviews.FindReviewsByOwner(user.Login)
+	query, err := parseReviewsQuery(w, r)
 	if err != nil {
+		return
+	}
+	page, err := store.Reviews.FindReviewsByOwner(user.Login, query)
+	if xerrors.Is(err, store.ErrIncorrectQuery) || xerrors.Is(err, store.ErrIncorrectCursor) {
+		logrus.Warnf("Incorrect reviews query: %+v", err)
+		incorrectReviewsQuery(w)
+		return
+	} else if err != nil {
 		logrus.Errorf("Cannot load outgoing reviews: %+v", err)
 		utils.Error(w, utils.InternalErrorResponse("Cannot load outgoing reviews"))
 		return
 	}
-	res, err := newAPIReviews(reviews)
+	res, err := newAPIReviewsPage(page)
 	if err != nil {
 		logrus.Errorf("Cannot load users from outgoing reviews: %+v", err)
 		utils.Error(w, utils.InternalErrorResponse("Cannot load outgoing reviews"))
@@ -150,13 +699,21 @@ var IncomingReviews = auth.Required(func(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	reviews, err := store.Reviews.FindReviewsByReviewer(user.Login)
+	query, err := parseReviewsQuery(w, r)
 	if err != nil {
-		logrus.Errorf("Cannot load outgoing reviews: %+v", err)
+		return
+	}
+	page, err := store.Reviews.FindReviewsByReviewer(user.Login, query)
+	if xerrors.Is(err, store.ErrIncorrectQuery) || xerrors.Is(err, store.ErrIncorrectCursor) {
+		logrus.Warnf("Incorrect reviews query: %+v", err)
+		incorrectReviewsQuery(w)
+		return
+	} else if err != nil {
+		logrus.Errorf("Cannot load incoming reviews: %+v", err)
 		utils.Error(w, utils.InternalErrorResponse("Cannot load incoming reviews"))
 		return
 	}
-	res, err := newAPIReviews(reviews)
+	res, err := newAPIReviewsPage(page)
 	if err != nil {
 		logrus.Errorf("Cannot load users from incoming reviews: %+v", err)
 		utils.Error(w, utils.InternalErrorResponse("Cannot load incoming reviews"))
@@ -175,23 +732,23 @@ var NewReview = auth.Required(func(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var form struct {
-		Name        string `json:"name" validate:"required"`
-		Reviewers   string `json:"reviewers" validate:"required"`
-		FileName    string `json:"file_name" validate:"required"`
-		FileContent string `json:"file_content" validate:"required"`
+		Name      string     `json:"name" validate:"required"`
+		Reviewers string     `json:"reviewers" validate:"required"`
+		Files     []fileForm `json:"files" validate:"dive"`
+		Archive   string     `json:"archive"`
+		// Description of uploaded revision
+		Description string `json:"description"`
+
+		ApprovalPolicy    string `json:"approval_policy"`
+		RequiredApprovals int    `json:"required_approvals"`
+		BlockUnresolved   bool   `json:"block_unresolved"`
 	}
 	if err := utils.UnmarshalForm(w, r, &form); err != nil {
 		return
 	}
 
-	fileContent, err := base64.StdEncoding.DecodeString(form.FileContent)
+	uploaded, err := uploadedFiles(w, form.Files, form.Archive)
 	if err != nil {
-		logrus.Warnf("Incorrect base64 file content: %s, error: %+v", form.FileContent, err)
-		utils.Error(w, utils.JSONErrorResponse{
-			Status:        http.StatusBadRequest,
-			Message:       "Incorrect base64 file content",
-			ClientMessage: "Некорректная кодировка файла",
-		})
 		return
 	}
 
@@ -209,28 +766,47 @@ var NewReview = auth.Required(func(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	file := NewVersionedFile(form.FileName, difflib.SplitLines(string(fileContent)))
-	bytesFile, err := json.Marshal(&file)
-	if err != nil {
-		logrus.Errorf("Cannot serialize versioned file: %+v", err)
-		utils.Error(w, utils.InternalErrorResponse("Cannot create versioned file"))
-		return
-	}
 	review := store.Review{
-		File:      bytesFile,
 		Name:      form.Name,
 		Owner:     user.Login,
 		Reviewers: reviewers,
 		Updated:   time.Now().Unix(),
+		State:     store.StateOpen,
 		Closed:    false,
 		Accepted:  false,
+
+		BlockUnresolved: form.BlockUnresolved,
+	}
+	err = setApprovalPolicy(&review, store.ApprovalPolicy(form.ApprovalPolicy), form.RequiredApprovals)
+	if err != nil {
+		incorrectPolicy(w, err)
+		return
+	}
+
+	files, err := NewVersionedFiles(uploaded, RevisionInfo{
+		Created:     review.Updated,
+		Author:      user.Login,
+		Description: form.Description,
+	})
+	if err != nil {
+		incorrectFiles(w, err)
+		return
+	}
+	bytesFile, err := json.Marshal(&files)
+	if err != nil {
+		logrus.Errorf("Cannot serialize versioned file: %+v", err)
+		utils.Error(w, utils.InternalErrorResponse("Cannot create versioned file"))
+		return
 	}
+	review.RevisionsCount = files.RevisionsCount()
 
-	err = store.Reviews.CreateReview(&review)
+	err = store.Reviews.CreateReviewWithFile(&review, &store.VersionedFile{Content: bytesFile}, uploadedBlobs(uploaded))
 	if err != nil {
 		logrus.Errorf("Cannot save new review: %+v", err)
 		utils.Error(w, utils.InternalErrorResponse("Cannot save review"))
+		return
 	}
+	saveEvents(review.ID, reviewEvents(store.Review{}, review, user.Login))
 	utils.Ok(w, nil)
 })
 
@@ -264,40 +840,20 @@ var Review = auth.Required(func(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
-	var file VersionedFile
-	err = json.Unmarshal(review.File, &file)
+	files, err := loadFiles(review)
 	if err != nil {
-		logrus.Errorf("Error while deserializing versioned file: %+v", err)
-		utils.Error(w, utils.InternalErrorResponse("Cannot deserialize versioned file"))
+		logrus.Errorf("Cannot load versioned files: %+v", err)
+		utils.Error(w, utils.InternalErrorResponse("Cannot load versioned files"))
 		return
 	}
 
-	getParamOr := func(param string, def int) (int, error) {
-		value := r.URL.Query()[param]
-		if len(value) > 0 {
-			return strconv.Atoi(value[0])
-		}
-		return def, nil
-	}
-
-	startRev, err := getParamOr("start_rev", 0)
+	startRev, endRev, err := parseRevisions(w, r, files)
 	if err != nil {
-		logrus.Warnf("Incorrect start revision: %+v", err)
-		utils.Error(w, utils.JSONErrorResponse{
-			Status:        http.StatusBadRequest,
-			Message:       "Incorrect start revision",
-			ClientMessage: "Некорректный номер начальной ревизии",
-		})
 		return
 	}
-	endRev, err := getParamOr("end_rev", file.RevisionsCount()-1)
+
+	options, err := parseDiffOptions(w, r)
 	if err != nil {
-		logrus.Warnf("Incorrect end revision: %+v", err)
-		utils.Error(w, utils.JSONErrorResponse{
-			Status:        http.StatusBadRequest,
-			Message:       "Incorrect end revision",
-			ClientMessage: "Некорректный номер конечной ревизии",
-		})
 		return
 	}
 
@@ -311,7 +867,7 @@ var Review = auth.Required(func(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	content, err := file.Diff(startRev, endRev)
+	content, err := files.DiffWithOptions(startRev, endRev, options)
 	if err != nil {
 		logrus.Errorf("Cannot calculate diff: %+v", err)
 		utils.Error(w, utils.InternalErrorResponse("Cannot calculate diff"))
@@ -324,6 +880,20 @@ var Review = auth.Required(func(w http.ResponseWriter, r *http.Request) {
 		utils.Error(w, utils.InternalErrorResponse("Cannot load comments"))
 		return
 	}
+	lineIDs := make(map[string]bool)
+	for _, comment := range comments {
+		for _, id := range []string{comment.LineID, comment.EndLineID} {
+			if len(id) > 0 {
+				lineIDs[id] = true
+			}
+		}
+	}
+	history, err := files.lineHistory(lineIDs)
+	if err != nil {
+		logrus.Errorf("Cannot find commented lines in review: %d, error: %+v", review.ID, err)
+		utils.Error(w, utils.InternalErrorResponse("Cannot load comments"))
+		return
+	}
 	apiComments := make(map[int]*APIComment)
 	for _, comment := range comments {
 		ac, err := NewAPIComment(comment)
@@ -332,6 +902,7 @@ var Review = auth.Required(func(w http.ResponseWriter, r *http.Request) {
 			utils.Error(w, utils.InternalErrorResponse("Cannot load comments"))
 			return
 		}
+		setLineHistory(&ac, history, startRev, endRev)
 		if comment.ParentID == 0 {
 			apiComments[comment.ID] = &ac
 		} else {
@@ -360,9 +931,11 @@ var Review = auth.Required(func(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	utils.Ok(w, &map[string]interface{}{
-		"info":     res,
-		"diff":     content,
-		"comments": resComments,
+		"info":        res,
+		"revisions":   newAPIRevisions(review, files),
+		"diff":        content,
+		"comments":    resComments,
+		"transitions": newAPITransitions(review),
 	})
 })
 
@@ -405,23 +978,36 @@ var UpdateReview = auth.Required(func(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	original := review
 
 	var form struct {
-		Name        string `json:"name" validate:"required"`
-		Reviewers   string `json:"reviewers" validate:"required"`
-		NewRevision string `json:"new_revision"`
+		Name      string     `json:"name" validate:"required"`
+		Reviewers string     `json:"reviewers" validate:"required"`
+		Files     []fileForm `json:"files" validate:"dive"`
+		Archive   string     `json:"archive"`
+		// Unified diff against the last revision
+		Patch string `json:"patch"`
+		// Description of uploaded revision
+		Description string `json:"description"`
+
+		ApprovalPolicy    string `json:"approval_policy"`
+		RequiredApprovals int    `json:"required_approvals"`
+		BlockUnresolved   *bool  `json:"block_unresolved"`
 	}
 	if err := utils.UnmarshalForm(w, r, &form); err != nil {
 		return
 	}
 
-	fileContent, err := base64.StdEncoding.DecodeString(form.NewRevision)
+	uploaded, err := uploadedFiles(w, form.Files, form.Archive)
 	if err != nil {
-		logrus.Warnf("Incorrect base64 file content: %s, error: %+v", form.NewRevision, err)
+		return
+	}
+	if len(form.Patch) > 0 && len(uploaded) > 0 {
+		logrus.Warnf("Both files and patch are specified")
 		utils.Error(w, utils.JSONErrorResponse{
-			Status:        http.StatusBadRequest,
-			Message:       "Incorrect base64 file content",
-			ClientMessage: "Некорректная кодировка файла",
+			Status:        http.StatusNotAcceptable,
+			Message:       "Both files and patch are specified",
+			ClientMessage: "Необходимо загрузить либо файлы, либо патч",
 		})
 		return
 	}
@@ -441,36 +1027,78 @@ var UpdateReview = auth.Required(func(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	review.Reviewers = reviewers
+	if form.BlockUnresolved != nil {
+		review.BlockUnresolved = *form.BlockUnresolved
+	}
+	if len(form.ApprovalPolicy) > 0 {
+		err = setApprovalPolicy(&review, store.ApprovalPolicy(form.ApprovalPolicy), form.RequiredApprovals)
+		if err != nil {
+			incorrectPolicy(w, err)
+			return
+		}
+	}
 
-	var file VersionedFile
-	err = json.Unmarshal(review.File, &file)
+	files, err := loadFiles(review)
 	if err != nil {
-		logrus.Errorf("Error while deserializing versioned file: %+v", err)
-		utils.Error(w, utils.InternalErrorResponse("Cannot deserialize versioned file"))
+		logrus.Errorf("Cannot load versioned files: %+v", err)
+		utils.Error(w, utils.InternalErrorResponse("Cannot load versioned files"))
 		return
 	}
-	if len(form.NewRevision) > 0 {
-		err = file.AddRevision(difflib.SplitLines(string(fileContent)))
+	if len(form.Patch) > 0 {
+		uploaded, err = patchedFiles(w, &files, form.Patch)
 		if err != nil {
-			logrus.Errorf("Cannot add revision: %+v", err)
-			utils.Error(w, utils.InternalErrorResponse("Cannot add revision"))
 			return
 		}
+	}
+	var storedFile *store.VersionedFile
+	if len(uploaded) > 0 {
+		if isClosed(reviewState(review)) {
+			logrus.Warnf("User %s tries to add revision to closed review %d", user.Login, review.ID)
+			utils.Error(w, utils.JSONErrorResponse{
+				Status:        http.StatusConflict,
+				Message:       "Review is closed",
+				ClientMessage: "Нельзя добавить ревизию в закрытое ревью. Сначала откройте его заново",
+			})
+			return
+		}
+		if reviewState(review) == store.StateChangesRequested {
+			err = changeState(&review, store.StateOpen, user.Login)
+			if err != nil {
+				incorrectTransition(w, err)
+				return
+			}
+		}
 		review.Updated = time.Now().Unix()
+		err = files.AddRevision(uploaded, RevisionInfo{
+			Created:     review.Updated,
+			Author:      user.Login,
+			Description: form.Description,
+		})
+		if err != nil {
+			incorrectFiles(w, err)
+			return
+		}
+		review.RevisionsCount = files.RevisionsCount()
+
+		bytesFile, err := json.Marshal(&files)
+		if err != nil {
+			logrus.Errorf("Cannot serialize versioned file: %+v", err)
+			utils.Error(w, utils.InternalErrorResponse("Cannot create versioned file"))
+			return
+		}
+		storedFile = &store.VersionedFile{ID: review.FileID, Content: bytesFile}
 	}
-	bytesFile, err := json.Marshal(&file)
-	if err != nil {
-		logrus.Errorf("Cannot serialize versioned file: %+v", err)
-		utils.Error(w, utils.InternalErrorResponse("Cannot create versioned file"))
-		return
+	if storedFile != nil {
+		err = store.Reviews.UpdateReviewWithFile(&review, storedFile, uploadedBlobs(uploaded))
+	} else {
+		err = store.Reviews.UpdateReview(&review)
 	}
-	review.File = bytesFile
-	err = store.Reviews.UpdateReview(&review)
 	if err != nil {
 		logrus.Errorf("Cannot save updated review: %+v", err)
 		utils.Error(w, utils.InternalErrorResponse("Cannot update review"))
 		return
 	}
+	saveEvents(review.ID, reviewEvents(original, review, user.Login))
 	utils.Ok(w, nil)
 })
 
@@ -503,7 +1131,7 @@ var Decline = auth.Required(func(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
-	if !hasAccess(user.Login, review) {
+	if review.Owner == user.Login || !hasAccess(user.Login, review) {
 		logrus.Warnf("User %s has no access to review %d", user.Login, review.ID)
 		utils.Error(w, utils.JSONErrorResponse{
 			Status:        http.StatusForbidden,
@@ -513,65 +1141,226 @@ var Decline = auth.Required(func(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	review.Closed = true
-	review.Accepted = false
+	original := review
+	err = changeState(&review, store.StateDeclined, user.Login)
+	if err != nil {
+		incorrectTransition(w, err)
+		return
+	}
 	err = store.Reviews.UpdateReview(&review)
 	if err != nil {
 		logrus.Errorf("Cannot save review: %+v", err)
 		utils.Error(w, utils.InternalErrorResponse("Cannot update review"))
 		return
 	}
+	saveEvents(review.ID, reviewEvents(original, review, user.Login))
+	utils.Ok(w, nil)
 })
 
-// Accept review
-var Accept = auth.Required(func(w http.ResponseWriter, r *http.Request) {
+// Reopen closed review
+var Reopen = auth.Required(func(w http.ResponseWriter, r *http.Request) {
 	user, err := auth.UserFromRequest(r)
 	if err != nil {
 		logrus.Errorf("Error while getting user from request context: %+v", err)
 		utils.Error(w, utils.InternalErrorResponse("No authorized user for this request"))
+		return
+	}
+	review, err := requestedReview(w, r, user.Login)
+	if err != nil {
+		return
 	}
 
-	vars := mux.Vars(r)
-	reviewID, err := strconv.Atoi(vars["id"])
+	original := review
+	err = reopenReview(&review, user.Login)
 	if err != nil {
-		logrus.Warnf("Incorrect ID: %s, error: %+v", vars["id"], err)
-		utils.Error(w, utils.JSONErrorResponse{
-			Status:        http.StatusNotFound,
-			Message:       "No review with id: " + vars["id"],
-			ClientMessage: "Не удалось найти ревью",
-		})
+		incorrectTransition(w, err)
 		return
 	}
-	review, err := store.Reviews.FindReviewByID(reviewID)
+	err = store.Reviews.UpdateReview(&review)
 	if err != nil {
-		logrus.Warnf("Cannot find review: %s, error: %+v", vars["id"], err)
+		logrus.Errorf("Cannot save review: %+v", err)
+		utils.Error(w, utils.InternalErrorResponse("Cannot update review"))
+		return
+	}
+	saveEvents(review.ID, reviewEvents(original, review, user.Login))
+	utils.Ok(w, nil)
+})
+
+// HiddenLines returns lines of diff of file, which were collapsed between groups
+var HiddenLines = auth.Required(func(w http.ResponseWriter, r *http.Request) {
+	_, files, err := accessibleReview(w, r)
+	if err != nil {
+		return
+	}
+
+	startRev, endRev, err := parseRevisions(w, r, files)
+	if err != nil {
+		return
+	}
+	options, err := parseDiffOptions(w, r)
+	if err != nil {
+		return
+	}
+	var oldRange, newRange diffRange
+	for param, value := range map[string]*int{
+		"old_from": &oldRange.From,
+		"old_to":   &oldRange.To,
+		"new_from": &newRange.From,
+		"new_to":   &newRange.To,
+	} {
+		*value, err = intParamOr(r, param, 0)
+		if err != nil {
+			logrus.Warnf("Incorrect range of lines: %+v", err)
+			utils.Error(w, utils.JSONErrorResponse{
+				Status:        http.StatusBadRequest,
+				Message:       "Incorrect range of lines",
+				ClientMessage: "Некорректный диапазон строк",
+			})
+			return
+		}
+	}
+
+	group, err := files.HiddenLines(startRev, endRev, r.URL.Query().Get("file"), oldRange, newRange, options)
+	if xerrors.Is(err, ErrUnknownFile) {
+		logrus.Warnf("Cannot find file: %+v", err)
 		utils.Error(w, utils.JSONErrorResponse{
 			Status:        http.StatusNotFound,
-			Message:       "No review with id: " + vars["id"],
-			ClientMessage: "Не удалось найти ревью",
+			Message:       "No such file in review",
+			ClientMessage: "Не удалось найти файл",
 		})
 		return
+	} else if err != nil {
+		logrus.Errorf("Cannot calculate diff: %+v", err)
+		utils.Error(w, utils.InternalErrorResponse("Cannot calculate diff"))
+		return
 	}
-	if review.Owner == user.Login || !hasAccess(user.Login, review) {
-		logrus.Warnf("User %s has no access to review %d", user.Login, review.ID)
+	utils.Ok(w, group)
+})
+
+// Patch returns changes between revisions of review as unified diff
+var Patch = auth.Required(func(w http.ResponseWriter, r *http.Request) {
+	review, files, err := accessibleReview(w, r)
+	if err != nil {
+		return
+	}
+	startRev, endRev, err := parseRevisions(w, r, files)
+	if err != nil {
+		return
+	}
+
+	patch, err := files.Patch(startRev, endRev)
+	if err != nil {
+		logrus.Warnf("Cannot create patch: %+v", err)
 		utils.Error(w, utils.JSONErrorResponse{
-			Status:        http.StatusForbidden,
-			Message:       "No access to this review",
-			ClientMessage: "У вас недостаточно прав для закрытия ревью",
+			Status:        http.StatusBadRequest,
+			Message:       "Incorrect revisions",
+			ClientMessage: "Некорректные номера ревизий",
 		})
 		return
 	}
+	filename := fmt.Sprintf("review-%d-%d-%d.patch", review.ID, startRev, endRev)
+	utils.Attachment(w, "text/x-diff; charset=utf-8", filename, patch)
+})
 
-	review.Closed = true
-	review.Accepted = true
-	err = store.Reviews.UpdateReview(&review)
+// Events returns activity timeline of review
+var Events = auth.Required(func(w http.ResponseWriter, r *http.Request) {
+	user, err := auth.UserFromRequest(r)
+	if err != nil {
+		logrus.Errorf("Error while getting user from request context: %+v", err)
+		utils.Error(w, utils.InternalErrorResponse("No authorized user for this request"))
+		return
+	}
+	review, err := requestedReview(w, r, user.Login)
 	if err != nil {
-		logrus.Errorf("Cannot save review: %+v", err)
-		utils.Error(w, utils.InternalErrorResponse("Cannot update review"))
 		return
 	}
+
+	events, err := store.Events.EventsForReview(review.ID)
+	if err != nil {
+		logrus.Errorf("Cannot load events for review: %d, error: %+v", review.ID, err)
+		utils.Error(w, utils.InternalErrorResponse("Cannot load events"))
+		return
+	}
+	utils.Ok(w, newAPIEvents(events))
 })
 
+// verdictHandler records verdict of reviewer for the last revision of review
+func verdictHandler(status store.VerdictStatus) http.HandlerFunc {
+	return auth.Required(func(w http.ResponseWriter, r *http.Request) {
+		user, err := auth.UserFromRequest(r)
+		if err != nil {
+			logrus.Errorf("Error while getting user from request context: %+v", err)
+			utils.Error(w, utils.InternalErrorResponse("No authorized user for this request"))
+			return
+		}
+
+		vars := mux.Vars(r)
+		reviewID, err := strconv.Atoi(vars["id"])
+		if err != nil {
+			logrus.Warnf("Incorrect ID: %s, error: %+v", vars["id"], err)
+			utils.Error(w, utils.JSONErrorResponse{
+				Status:        http.StatusNotFound,
+				Message:       "No review with id: " + vars["id"],
+				ClientMessage: "Не удалось найти ревью",
+			})
+			return
+		}
+		review, err := store.Reviews.FindReviewByID(reviewID)
+		if err != nil {
+			logrus.Warnf("Cannot find review: %s, error: %+v", vars["id"], err)
+			utils.Error(w, utils.JSONErrorResponse{
+				Status:        http.StatusNotFound,
+				Message:       "No review with id: " + vars["id"],
+				ClientMessage: "Не удалось найти ревью",
+			})
+			return
+		}
+		if review.Owner == user.Login || !hasAccess(user.Login, review) {
+			logrus.Warnf("User %s has no access to review %d", user.Login, review.ID)
+			utils.Error(w, utils.JSONErrorResponse{
+				Status:        http.StatusForbidden,
+				Message:       "No access to this review",
+				ClientMessage: "Только ревьюеры могут оценивать ревью",
+			})
+			return
+		}
+		comments, err := store.Comments.CommentsForReview(review.ID)
+		if err != nil {
+			logrus.Errorf("Cannot load comments for review: %d, error: %+v", review.ID, err)
+			utils.Error(w, utils.InternalErrorResponse("Cannot load comments"))
+			return
+		}
+		original := review
+		err = setVerdict(&review, user.Login, status, comments)
+		if xerrors.Is(err, ErrUnresolvedThreads) {
+			logrus.Warnf("User %s tries to approve review %d: %+v", user.Login, review.ID, err)
+			utils.Error(w, utils.JSONErrorResponse{
+				Status:        http.StatusConflict,
+				Message:       "Review has unresolved threads",
+				ClientMessage: "Нельзя принять ревью, пока есть нерешённые обсуждения",
+			})
+			return
+		} else if err != nil {
+			incorrectTransition(w, err)
+			return
+		}
+		err = store.Reviews.UpdateReview(&review)
+		if err != nil {
+			logrus.Errorf("Cannot save review: %+v", err)
+			utils.Error(w, utils.InternalErrorResponse("Cannot update review"))
+			return
+		}
+		saveEvents(review.ID, reviewEvents(original, review, user.Login))
+		utils.Ok(w, nil)
+	})
+}
+
+// Accept review: approve the last revision by reviewer
+var Accept = verdictHandler(store.VerdictApproved)
+
+// RequestChanges in the last revision of review by reviewer
+var RequestChanges = verdictHandler(store.VerdictChangesRequested)
+
 // SearchReviewer by login or by name
 var SearchReviewer = auth.Required(func(w http.ResponseWriter, r *http.Request) {
 	user, err := auth.UserFromRequest(r)
@@ -607,3 +1396,75 @@ var SearchReviewer = auth.Required(func(w http.ResponseWriter, r *http.Request)
 	}
 	utils.Ok(w, res)
 })
+
+// revisionFromVars parses number of revision from url. If error occurs, writes error message to response writer
+func revisionFromVars(w http.ResponseWriter, r *http.Request, files VersionedFiles) (int, error) {
+	vars := mux.Vars(r)
+	revision, err := strconv.Atoi(vars["rev"])
+	if err == nil && (revision < 0 || revision >= files.RevisionsCount()) {
+		err = xerrors.Errorf("Bad revision: expected from %d to %d, got %d", 0, files.RevisionsCount()-1, revision)
+	}
+	if err != nil {
+		logrus.Warnf("Incorrect revision: %s, error: %+v", vars["rev"], err)
+		utils.Error(w, utils.JSONErrorResponse{
+			Status:        http.StatusNotFound,
+			Message:       "No revision: " + vars["rev"],
+			ClientMessage: "Не удалось найти ревизию",
+		})
+		return 0, err
+	}
+	return revision, nil
+}
+
+// RawRevision returns content of file in revision. If name of file is not specified and revision
+// contains several files, zip archive with all files is returned
+var RawRevision = auth.Required(func(w http.ResponseWriter, r *http.Request) {
+	review, files, err := accessibleReview(w, r)
+	if err != nil {
+		return
+	}
+	revision, err := revisionFromVars(w, r, files)
+	if err != nil {
+		return
+	}
+
+	name := r.URL.Query().Get("file")
+	if rev, _ := files.GetRevision(revision); len(name) == 0 && len(rev.Files) == 1 {
+		name = rev.Files[0].Name
+	}
+	if len(name) == 0 {
+		archive, err := files.Archive(revision, loadBlob)
+		if err != nil {
+			logrus.Errorf("Cannot create archive: %+v", err)
+			utils.Error(w, utils.InternalErrorResponse("Cannot create archive"))
+			return
+		}
+		utils.Attachment(w, "application/zip", fmt.Sprintf("review-%d-%d.zip", review.ID, revision), archive)
+		return
+	}
+
+	content, fr, err := files.RawFile(revision, name, loadBlob)
+	if xerrors.Is(err, ErrFileNotFound) {
+		logrus.Warnf("Cannot get file %s: %+v", name, err)
+		utils.Error(w, utils.JSONErrorResponse{
+			Status:        http.StatusNotFound,
+			Message:       "No such file in revision",
+			ClientMessage: "Файл отсутствует в ревизии",
+		})
+		return
+	} else if err != nil {
+		logrus.Errorf("Cannot get content of file %s: %+v", name, err)
+		utils.Error(w, utils.InternalErrorResponse("Cannot get content of file"))
+		return
+	}
+	if fr.Binary != nil {
+		utils.Attachment(w, "application/octet-stream", path.Base(name), content)
+		return
+	}
+	encoding := fr.Encoding
+	if len(encoding) == 0 {
+		encoding = EncodingUTF8
+	}
+	// Content is always sent as plain text, so uploaded html or scripts are not executed by browser
+	utils.Attachment(w, "text/plain; charset="+encoding, path.Base(name), content)
+})
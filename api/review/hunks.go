@@ -0,0 +1,49 @@
+package review
+
+// hunks returns ranges of lines of diff, which contain changes with context lines around them.
+// Hunks, which are closer than 2*context lines, are merged
+func (b *diffBuilder) hunks(context int) []diffRange {
+	result := make([]diffRange, 0)
+	for k, line := range b.lines {
+		if line.Type == NoOperation {
+			continue
+		}
+		from, to := k-context, k+context+1
+		if from < 0 {
+			from = 0
+		}
+		if to > len(b.lines) {
+			to = len(b.lines)
+		}
+		if len(result) > 0 && from <= result[len(result)-1].To {
+			result[len(result)-1].To = to
+			continue
+		}
+		result = append(result, diffRange{From: from, To: to})
+	}
+	return result
+}
+
+// hidden returns lines of diff, which are placed in specified ranges of the old and the new files.
+// It is used to expand context of collapsed diff
+func (b *diffBuilder) hidden(oldRange, newRange diffRange) DiffGroup {
+	from, to := len(b.lines), len(b.lines)
+	for k, line := range b.lines {
+		pos := b.position(k)
+		if (line.Old != nil && pos.Old >= oldRange.From && pos.Old < oldRange.To) ||
+			(line.New != nil && pos.New >= newRange.From && pos.New < newRange.To) {
+			if from == len(b.lines) {
+				from = k
+			}
+			to = k + 1
+		}
+	}
+	if from == len(b.lines) {
+		return DiffGroup{
+			OldRange: diffRange{From: oldRange.From, To: oldRange.From},
+			NewRange: diffRange{From: newRange.From, To: newRange.From},
+			Lines:    []DiffLine{},
+		}
+	}
+	return b.group(from, to)
+}
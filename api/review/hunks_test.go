@@ -0,0 +1,114 @@
+package review
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+)
+
+// numberedLines returns content of n lines, lines with specified numbers are replaced
+func numberedLines(n int, changed ...int) string {
+	lines := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		lines = append(lines, fmt.Sprintf("line %d\n", i))
+	}
+	for _, i := range changed {
+		lines[i] = fmt.Sprintf("changed %d\n", i)
+	}
+	return strings.Join(lines, "")
+}
+
+func TestDiffContext(t *testing.T) {
+	file := NewVersionedFile(fileName, splitLines(numberedLines(30)))
+	require.NoError(t, file.AddRevision(splitLines(numberedLines(30, 5, 25))))
+
+	diff, err := file.Diff(0, 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(diff.Groups))
+	assert.Equal(t, 32, len(diff.Groups[0].Lines))
+
+	diff, err = file.DiffWithOptions(0, 1, DiffOptions{Collapse: true, Context: 3})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(diff.Groups))
+	assert.Equal(t, diffRange{From: 2, To: 9}, diff.Groups[0].OldRange)
+	assert.Equal(t, diffRange{From: 2, To: 9}, diff.Groups[0].NewRange)
+	assert.Equal(t, 8, len(diff.Groups[0].Lines))
+	assert.Equal(t, diffRange{From: 22, To: 29}, diff.Groups[1].OldRange)
+	assert.Equal(t, "@@ -3,7 +3,7 @@\n", strings.SplitAfter(diff.Groups[0].String(), "\n")[0])
+
+	// Close hunks are merged
+	diff, err = file.DiffWithOptions(0, 1, DiffOptions{Collapse: true, Context: 10})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(diff.Groups))
+	assert.Equal(t, diffRange{From: 0, To: 30}, diff.Groups[0].OldRange)
+
+	// Content of the same revision is not collapsed
+	diff, err = file.DiffWithOptions(0, 0, DiffOptions{Collapse: true, Context: 3})
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(diff.Groups))
+}
+
+func TestUnchangedFileCollapsed(t *testing.T) {
+	files, err := NewVersionedFiles([]UploadedFile{
+		uploaded(fileName, numberedLines(30)),
+		uploaded("other.cpp", numberedLines(30)),
+	}, RevisionInfo{})
+	require.NoError(t, err)
+	require.NoError(t, files.AddRevision([]UploadedFile{
+		uploaded(fileName, numberedLines(30, 5)),
+		uploaded("other.cpp", numberedLines(30)),
+	}, RevisionInfo{}))
+	options := DiffOptions{Collapse: true, Context: 3}
+
+	diffs, err := files.DiffWithOptions(0, 1, options)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(diffs))
+	assert.Equal(t, 1, len(diffs[0].Groups))
+	assert.Empty(t, diffs[1].Groups)
+
+	// Unchanged lines are still available as hidden lines
+	group, err := files.HiddenLines(0, 1, "other.cpp", diffRange{From: 0, To: 30}, diffRange{From: 0, To: 30}, options)
+	require.NoError(t, err)
+	assert.Equal(t, 30, len(group.Lines))
+
+	// Whole content is returned without collapsing and for single revision
+	diffs, err = files.DiffWithOptions(0, 1, DiffOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 30, len(diffs[1].Groups[0].Lines))
+	diffs, err = files.DiffWithOptions(1, 1, options)
+	require.NoError(t, err)
+	assert.Equal(t, 30, len(diffs[1].Groups[0].Lines))
+}
+
+func TestHiddenLines(t *testing.T) {
+	files, err := NewVersionedFiles([]UploadedFile{uploaded(fileName, numberedLines(30))}, RevisionInfo{})
+	require.NoError(t, err)
+	require.NoError(t, files.AddRevision([]UploadedFile{uploaded(fileName, numberedLines(30, 5, 25))}, RevisionInfo{}))
+	options := DiffOptions{Collapse: true, Context: 3}
+	diffs, err := files.DiffWithOptions(0, 1, options)
+	require.NoError(t, err)
+	first, second := diffs[0].Groups[0], diffs[0].Groups[1]
+
+	group, err := files.HiddenLines(0, 1, fileName,
+		diffRange{From: first.OldRange.To, To: second.OldRange.From},
+		diffRange{From: first.NewRange.To, To: second.NewRange.From}, options)
+	require.NoError(t, err)
+	assert.Equal(t, diffRange{From: 9, To: 22}, group.OldRange)
+	assert.Equal(t, diffRange{From: 9, To: 22}, group.NewRange)
+	require.Equal(t, 13, len(group.Lines))
+	assert.Equal(t, "line 9\n", group.Lines[0].Old.Content)
+	assert.Equal(t, "line 21\n", group.Lines[12].New.Content)
+
+	// Lines after the last group
+	group, err = files.HiddenLines(0, 1, fileName, diffRange{From: 29, To: 40}, diffRange{From: 29, To: 40}, options)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(group.Lines))
+	assert.Equal(t, diffRange{From: 29, To: 30}, group.NewRange)
+
+	_, err = files.HiddenLines(0, 1, otherName, diffRange{}, diffRange{}, options)
+	assert.True(t, xerrors.Is(err, ErrUnknownFile))
+}
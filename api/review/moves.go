@@ -0,0 +1,100 @@
+package review
+
+import (
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+const (
+	// minMovedLines is a minimal size of block of lines, which is considered to be moved
+	minMovedLines = 3
+	// minSignificantMovedLines is a minimal count of lines in moved block, which are not blank and
+	// not single symbols like braces. Such lines are too common to detect moves by them
+	minSignificantMovedLines = 2
+)
+
+func isSignificant(line string) bool {
+	return len(strings.TrimSpace(line)) > 1
+}
+
+// detectMoves finds blocks of lines, which were deleted from one place of file and inserted to another.
+// Returns lines of previous revision for positions of moved lines in new content
+func detectMoves(previous []Line, content []string, codes []difflib.OpCode) map[int]Line {
+	deleted := make(map[int]bool)
+	inserted := make([]int, 0)
+	for _, c := range codes {
+		if c.Tag == 'e' {
+			continue
+		}
+		for i := c.I1; i < c.I2; i++ {
+			deleted[i] = true
+		}
+		for j := c.J1; j < c.J2; j++ {
+			inserted = append(inserted, j)
+		}
+	}
+	candidates := make(map[string][]int)
+	for i, line := range previous {
+		if deleted[i] {
+			candidates[line.Content] = append(candidates[line.Content], i)
+		}
+	}
+
+	moves := make(map[int]Line)
+	isInserted := make(map[int]bool)
+	for _, j := range inserted {
+		isInserted[j] = true
+	}
+	for k := 0; k < len(inserted); k++ {
+		j := inserted[k]
+		bestFrom, bestLen := 0, 0
+		for _, i := range candidates[content[j]] {
+			length := 0
+			for i+length < len(previous) && j+length < len(content) && deleted[i+length] &&
+				isInserted[j+length] && previous[i+length].Content == content[j+length] {
+				length++
+			}
+			if length > bestLen {
+				bestFrom, bestLen = i, length
+			}
+		}
+		significant := 0
+		for l := 0; l < bestLen; l++ {
+			if isSignificant(content[j+l]) {
+				significant++
+			}
+		}
+		if bestLen < minMovedLines || significant < minSignificantMovedLines {
+			continue
+		}
+		for l := 0; l < bestLen; l++ {
+			moves[j+l] = previous[bestFrom+l]
+			// Lines of previous revision can be moved only once
+			delete(deleted, bestFrom+l)
+		}
+		k += bestLen - 1
+	}
+	return moves
+}
+
+// markMoved marks deleted and inserted lines of diff, which have the same IDs
+func markMoved(lines []DiffLine) {
+	deleted := make(map[string]bool)
+	inserted := make(map[string]bool)
+	for _, line := range lines {
+		if line.Type == DeleteOperation {
+			deleted[line.Old.ID] = true
+		} else if line.Type == InsertOperation {
+			inserted[line.New.ID] = true
+		}
+	}
+	for k := range lines {
+		line := &lines[k]
+		if line.Type == DeleteOperation && inserted[line.Old.ID] {
+			line.Moved = true
+		} else if line.Type == InsertOperation && deleted[line.New.ID] {
+			line.Moved = true
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package review
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	functionF = "int f(int x) {\n    int y = x * 2;\n    return y + 1;\n}\n"
+	functionG = "int g() {\n    return 42;\n}\n"
+	mainBody  = "int main() {\n    return f(g());\n}\n"
+)
+
+func TestMovedBlockKeepsIDs(t *testing.T) {
+	file := NewVersionedFile(fileName, splitLines(functionF+"\n"+functionG+"\n"+mainBody))
+	require.NoError(t, file.AddRevision(splitLines(functionG+"\n"+mainBody+"\n"+functionF)))
+	first, err := file.GetRevision(0)
+	require.NoError(t, err)
+	second, err := file.GetRevision(1)
+	require.NoError(t, err)
+
+	ids := make(map[string]bool)
+	for _, line := range first.Lines {
+		ids[line.ID] = true
+	}
+	// Function f was moved to the end of file
+	for _, line := range second.Lines[len(second.Lines)-4:] {
+		assert.True(t, ids[line.ID], line.Content)
+		assert.Equal(t, 0, line.Revision)
+	}
+
+	diff, err := file.Diff(0, 1)
+	require.NoError(t, err)
+	moved := 0
+	for _, line := range diff.Groups[0].Lines {
+		if line.Moved {
+			moved++
+			assert.NotEqual(t, NoOperation, line.Type)
+		}
+	}
+	// Lines of f are marked both in old and new places
+	assert.Equal(t, 8, moved)
+}
+
+func TestSmallBlocksAreNotMoved(t *testing.T) {
+	old := splitLines("a\n}\n\nb\nc\n")
+	file := NewVersionedFile(fileName, old)
+	first, err := file.GetRevision(0)
+	require.NoError(t, err)
+	// Only closing brace and blank line are moved
+	content := splitLines("b\nc\n}\n\nd\n")
+	codes := defaultAlgorithm().OpCodes(old, content)
+	assert.Empty(t, detectMoves(first.Lines, content, codes))
+}
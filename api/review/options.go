@@ -0,0 +1,84 @@
+package review
+
+import (
+	"strings"
+	"unicode"
+)
+
+// DiffOptions specifies differences between lines, which are ignored by diff
+type DiffOptions struct {
+	IgnoreAllSpace    bool
+	IgnoreSpaceChange bool
+	IgnoreBlankLines  bool
+	IgnoreLineEndings bool
+
+	// Collapse unchanged lines, which are farther than Context lines from changes.
+	// Otherwise the whole file is returned as one group
+	Collapse bool
+	Context  int
+
+	// Algorithm used to match lines, server default is used if it is not specified
+	Algorithm DiffAlgorithm
+}
+
+func (options DiffOptions) algorithm() DiffAlgorithm {
+	if options.Algorithm == nil {
+		return defaultAlgorithm()
+	}
+	return options.Algorithm
+}
+
+// normalize line before comparison according to options
+func (options DiffOptions) normalize(line string) string {
+	if options.IgnoreAllSpace {
+		return strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) {
+				return -1
+			}
+			return r
+		}, line)
+	}
+	if options.IgnoreSpaceChange {
+		// Sequences of spaces are equivalent, spaces at the end of line are ignored
+		var builder strings.Builder
+		space := false
+		for _, r := range line {
+			if unicode.IsSpace(r) {
+				space = true
+				continue
+			}
+			if space {
+				builder.WriteByte(' ')
+				space = false
+			}
+			builder.WriteRune(r)
+		}
+		return builder.String()
+	}
+	if options.IgnoreLineEndings && strings.HasSuffix(line, "\r\n") {
+		return strings.TrimSuffix(line, "\r\n") + "\n"
+	}
+	return line
+}
+
+// comparedLines contains normalized lines, which take part in comparison, and their positions in file
+type comparedLines struct {
+	Content   []string
+	Positions []int
+}
+
+// compared returns lines of file, which should be compared according to options
+func (options DiffOptions) compared(content []string) comparedLines {
+	result := comparedLines{
+		Content:   make([]string, 0, len(content)),
+		Positions: make([]int, 0, len(content)),
+	}
+	for i, line := range content {
+		if options.IgnoreBlankLines && len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		result.Content = append(result.Content, options.normalize(line))
+		result.Positions = append(result.Positions, i)
+	}
+	return result
+}
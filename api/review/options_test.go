@@ -0,0 +1,87 @@
+package review
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	line := "\tint  x =\t1;  \r\n"
+	assert.Equal(t, line, DiffOptions{}.normalize(line))
+	assert.Equal(t, "intx=1;", DiffOptions{IgnoreAllSpace: true}.normalize(line))
+	assert.Equal(t, " int x = 1;", DiffOptions{IgnoreSpaceChange: true}.normalize(line))
+	assert.Equal(t, "\tint  x =\t1;  \n", DiffOptions{IgnoreLineEndings: true}.normalize(line))
+}
+
+func diffTypes(diff Diff) []DiffType {
+	result := make([]DiffType, 0)
+	for _, g := range diff.Groups {
+		for _, line := range g.Lines {
+			result = append(result, line.Type)
+		}
+	}
+	return result
+}
+
+func TestDiffIgnoreSpace(t *testing.T) {
+	file := NewVersionedFile(fileName, splitLines("int main() {\nreturn 0;\n}\n"))
+	require.NoError(t, file.AddRevision(splitLines("int main() {\n    return  0;\r\n}\n")))
+
+	diff, err := file.Diff(0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []DiffType{NoOperation, DeleteOperation, InsertOperation, NoOperation}, diffTypes(diff))
+
+	diff, err = file.DiffWithOptions(0, 1, DiffOptions{IgnoreAllSpace: true})
+	require.NoError(t, err)
+	assert.Equal(t, []DiffType{NoOperation, NoOperation, NoOperation}, diffTypes(diff))
+	// Lines still point to their own revisions
+	line := diff.Groups[0].Lines[1]
+	assert.Equal(t, 0, line.Old.Revision)
+	assert.Equal(t, 1, line.New.Revision)
+	assert.NotEqual(t, line.Old.ID, line.New.ID)
+}
+
+func TestDiffIgnoreBlankLines(t *testing.T) {
+	file := NewVersionedFile(fileName, splitLines("a\nb\n\nc\n"))
+	require.NoError(t, file.AddRevision(splitLines("a\n\n\nb\nc\nd\n")))
+
+	diff, err := file.DiffWithOptions(0, 1, DiffOptions{IgnoreBlankLines: true})
+	require.NoError(t, err)
+	assert.Equal(t, []DiffType{
+		NoOperation, NoOperation, NoOperation, NoOperation, NoOperation, NoOperation, InsertOperation,
+	}, diffTypes(diff))
+	lines := diff.Groups[0].Lines
+	// Blank lines, which are present only in one revision, have only one side
+	assert.Nil(t, lines[2].Old)
+	assert.Equal(t, "\n", lines[2].New.Content)
+	assert.Equal(t, "b\n", lines[3].Old.Content)
+	assert.Equal(t, "b\n", lines[3].New.Content)
+	assert.Equal(t, "\n", lines[4].Old.Content)
+	assert.Nil(t, lines[4].New)
+	assert.Equal(t, "d\n", lines[6].New.Content)
+}
+
+func TestDiffIgnoreBlankLinesInChanges(t *testing.T) {
+	// Blank line is added inside of modified block
+	file := NewVersionedFile(fileName, splitLines("a\nb\nc\nd\n"))
+	require.NoError(t, file.AddRevision(splitLines("a\nB\n\nC\nd\n")))
+
+	diff, err := file.DiffWithOptions(0, 1, DiffOptions{IgnoreBlankLines: true})
+	require.NoError(t, err)
+	assert.Equal(t, []DiffType{
+		NoOperation, DeleteOperation, DeleteOperation, InsertOperation, NoOperation, InsertOperation, NoOperation,
+	}, diffTypes(diff))
+	lines := diff.Groups[0].Lines
+	assert.Equal(t, "B\n", lines[3].New.Content)
+	assert.Nil(t, lines[4].Old)
+	assert.Equal(t, "\n", lines[4].New.Content)
+	assert.Equal(t, "C\n", lines[5].New.Content)
+
+	diff, err = file.Diff(0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []DiffType{
+		NoOperation, DeleteOperation, DeleteOperation, InsertOperation, InsertOperation, InsertOperation, NoOperation,
+	}, diffTypes(diff))
+}
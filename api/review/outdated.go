@@ -0,0 +1,146 @@
+package review
+
+// outdatedContext is a count of lines before and after commented line in context of outdated comment
+const outdatedContext = 2
+
+// LineHistory describes revisions of review, in which line exists
+type LineHistory struct {
+	Introduced int
+	// Removed is the first revision without line, -1 if line exists in the last revision
+	Removed int
+	// Context of line in the last revision, where it exists
+	File     string
+	Revision int
+	Context  []Line
+	// Line is an index of line in context
+	Line int
+}
+
+// merge histories of first and last lines of range. Lines of range exist together at least in one
+// revision, so range exists in revisions, where any of its lines exists
+func (h LineHistory) merge(other LineHistory) LineHistory {
+	result := h
+	if other.Revision > h.Revision {
+		result = other
+	}
+	if other.Introduced < h.Introduced {
+		result.Introduced = other.Introduced
+	} else {
+		result.Introduced = h.Introduced
+	}
+	if h.Removed < 0 || other.Removed < 0 {
+		result.Removed = -1
+	} else if other.Removed > h.Removed {
+		result.Removed = other.Removed
+	} else {
+		result.Removed = h.Removed
+	}
+	return result
+}
+
+// existsIn checks if line exists in specified revision of review
+func (h LineHistory) existsIn(revision int) bool {
+	return h.Introduced <= revision && (h.Removed < 0 || revision < h.Removed)
+}
+
+// eachFileRevision calls fn for each file of each revision of review
+func (files *VersionedFiles) eachFileRevision(fn func(revision int, fr FileRevision, file File)) error {
+	// Unchanged files are shared between revisions of review, so they are rebuilt once
+	cache := make(map[[2]int]File)
+	for revision, rev := range files.Revisions {
+		for _, fr := range rev.Files {
+			key := [2]int{fr.File, fr.Revision}
+			file, cached := cache[key]
+			if !cached {
+				var err error
+				file, err = files.Files[fr.File].GetRevision(fr.Revision)
+				if err != nil {
+					return err
+				}
+				cache[key] = file
+			}
+			fn(revision, fr, file)
+		}
+	}
+	return nil
+}
+
+// lineHistory finds revisions, in which lines with specified IDs were introduced and removed
+func (files *VersionedFiles) lineHistory(ids map[string]bool) (map[string]LineHistory, error) {
+	result := make(map[string]LineHistory)
+	if len(ids) == 0 {
+		return result, nil
+	}
+	err := files.eachFileRevision(func(revision int, fr FileRevision, file File) {
+		for i, line := range file.Lines {
+			if !ids[line.ID] {
+				continue
+			}
+			h, seen := result[line.ID]
+			if !seen {
+				h.Introduced = revision
+			}
+			from, to := i-outdatedContext, i+outdatedContext+1
+			if from < 0 {
+				from = 0
+			}
+			if to > len(file.Lines) {
+				to = len(file.Lines)
+			}
+			h.File = fr.Name
+			h.Revision = revision
+			h.Context = file.Lines[from:to]
+			h.Line = i - from
+			result[line.ID] = h
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	for id, h := range result {
+		h.Removed = -1
+		if h.Revision < len(files.Revisions)-1 {
+			h.Removed = h.Revision + 1
+		}
+		result[id] = h
+	}
+	return result, nil
+}
+
+// APICommentContext represents api result struct
+type APICommentContext struct {
+	File     string `json:"file"`
+	Revision int    `json:"revision"`
+	Lines    []Line `json:"lines"`
+	Line     int    `json:"line"`
+}
+
+// setLineHistory of comment. Comment is outdated, if its lines are absent in both compared revisions.
+// Comments on the whole review are never outdated
+func setLineHistory(comment *APIComment, history map[string]LineHistory, startRev, endRev int) {
+	h, exists := history[comment.LineID]
+	if last, ok := history[comment.EndLineID]; ok {
+		if exists {
+			h = h.merge(last)
+		} else {
+			h, exists = last, true
+		}
+	}
+	if !exists {
+		return
+	}
+	comment.IntroducedRevision = h.Introduced
+	if h.Removed >= 0 {
+		removed := h.Removed
+		comment.RemovedRevision = &removed
+	}
+	comment.Outdated = !h.existsIn(startRev) && !h.existsIn(endRev)
+	if comment.Outdated {
+		comment.Context = &APICommentContext{
+			File:     h.File,
+			Revision: h.Revision,
+			Lines:    h.Context,
+			Line:     h.Line,
+		}
+	}
+}
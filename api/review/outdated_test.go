@@ -0,0 +1,92 @@
+package review
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLineHistory(t *testing.T) {
+	files, err := NewVersionedFiles([]UploadedFile{uploaded(fileName, numberedLines(10))}, RevisionInfo{})
+	require.NoError(t, err)
+	require.NoError(t, files.AddRevision([]UploadedFile{uploaded(fileName, numberedLines(10, 5))}, RevisionInfo{}))
+	require.NoError(t, files.AddRevision([]UploadedFile{uploaded(fileName, numberedLines(10, 5))}, RevisionInfo{}))
+	first, err := files.Files[0].GetRevision(0)
+	require.NoError(t, err)
+	second, err := files.Files[0].GetRevision(1)
+	require.NoError(t, err)
+	removed, added, kept := first.Lines[5].ID, second.Lines[5].ID, first.Lines[0].ID
+
+	history, err := files.lineHistory(map[string]bool{removed: true, added: true, kept: true})
+	require.NoError(t, err)
+	assert.Equal(t, 0, history[removed].Introduced)
+	assert.Equal(t, 1, history[removed].Removed)
+	assert.Equal(t, 1, history[added].Introduced)
+	assert.Equal(t, -1, history[added].Removed)
+	assert.Equal(t, -1, history[kept].Removed)
+
+	// Context of removed line is taken from the last revision, where it exists
+	h := history[removed]
+	assert.Equal(t, 0, h.Revision)
+	assert.Equal(t, fileName, h.File)
+	assert.Equal(t, first.Lines[3:8], h.Context)
+	assert.Equal(t, 2, h.Line)
+	assert.Equal(t, first.Lines[:3], history[kept].Context)
+
+	comment := APIComment{LineID: removed}
+	setLineHistory(&comment, history, 1, 2)
+	assert.True(t, comment.Outdated)
+	assert.Equal(t, 1, *comment.RemovedRevision)
+	require.NotNil(t, comment.Context)
+	assert.Equal(t, first.Lines[5], comment.Context.Lines[comment.Context.Line])
+
+	// Removed line is shown in diff with revision, where it exists
+	comment = APIComment{LineID: removed}
+	setLineHistory(&comment, history, 0, 2)
+	assert.False(t, comment.Outdated)
+	assert.Nil(t, comment.Context)
+
+	// Line, which is added later, is outdated for previous revisions too
+	comment = APIComment{LineID: added}
+	setLineHistory(&comment, history, 0, 0)
+	assert.True(t, comment.Outdated)
+	assert.Nil(t, comment.RemovedRevision)
+	assert.Equal(t, 2, comment.Context.Revision)
+}
+
+func TestRangeHistory(t *testing.T) {
+	files, err := NewVersionedFiles([]UploadedFile{uploaded(fileName, numberedLines(10))}, RevisionInfo{})
+	require.NoError(t, err)
+	require.NoError(t, files.AddRevision([]UploadedFile{uploaded(fileName, numberedLines(10, 5))}, RevisionInfo{}))
+	require.NoError(t, files.AddRevision([]UploadedFile{uploaded(fileName, numberedLines(10, 4, 6))}, RevisionInfo{}))
+	first, err := files.Files[0].GetRevision(0)
+	require.NoError(t, err)
+	second, err := files.Files[0].GetRevision(1)
+	require.NoError(t, err)
+	history, err := files.lineHistory(map[string]bool{
+		first.Lines[4].ID: true, first.Lines[5].ID: true, first.Lines[6].ID: true, second.Lines[5].ID: true,
+	})
+	require.NoError(t, err)
+
+	// Range is kept, while any of its lines exists
+	comment := APIComment{LineID: first.Lines[4].ID, EndLineID: first.Lines[6].ID}
+	setLineHistory(&comment, history, 1, 1)
+	assert.False(t, comment.Outdated)
+	assert.Equal(t, 0, comment.IntroducedRevision)
+	assert.Equal(t, 2, *comment.RemovedRevision)
+
+	comment = APIComment{LineID: first.Lines[5].ID, EndLineID: second.Lines[5].ID}
+	setLineHistory(&comment, history, 0, 1)
+	assert.False(t, comment.Outdated)
+	setLineHistory(&comment, history, 2, 2)
+	assert.True(t, comment.Outdated)
+	assert.Equal(t, 1, comment.Context.Revision)
+	assert.Equal(t, second.Lines[5], comment.Context.Lines[comment.Context.Line])
+
+	// Comment on the whole review is never outdated
+	comment = APIComment{}
+	setLineHistory(&comment, history, 2, 2)
+	assert.False(t, comment.Outdated)
+	assert.Nil(t, comment.Context)
+}
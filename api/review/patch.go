@@ -0,0 +1,69 @@
+package review
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// patchContext is a count of context lines in hunks of patch
+const patchContext = 3
+
+// hasChanges checks if group contains changed lines
+func (group DiffGroup) hasChanges() bool {
+	for _, line := range group.Lines {
+		if line.Type != NoOperation {
+			return true
+		}
+	}
+	return false
+}
+
+// Patch returns changes between two revisions of review in git unified diff format
+func (files *VersionedFiles) Patch(revision1, revision2 int) ([]byte, error) {
+	diffs, err := files.DiffWithOptions(revision1, revision2, DiffOptions{Collapse: true, Context: patchContext})
+	if err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	for _, diff := range diffs {
+		oldName, newName := diff.FileName, diff.FileName
+		if len(diff.OldFileName) > 0 {
+			oldName = diff.OldFileName
+		}
+		groups := make([]DiffGroup, 0, len(diff.Groups))
+		for _, g := range diff.Groups {
+			if g.hasChanges() {
+				groups = append(groups, g)
+			}
+		}
+		binaryChanged := diff.Binary != nil && diff.Binary.Changed
+		if len(groups) == 0 && !binaryChanged && oldName == newName && !diff.Added && !diff.Removed {
+			continue
+		}
+
+		buffer.WriteString(fmt.Sprintf("diff --git a/%s b/%s\n", oldName, newName))
+		oldPath, newPath := "a/"+oldName, "b/"+newName
+		switch {
+		case diff.Added:
+			buffer.WriteString("new file mode 100644\n")
+			oldPath = "/dev/null"
+		case diff.Removed:
+			buffer.WriteString("deleted file mode 100644\n")
+			newPath = "/dev/null"
+		case oldName != newName:
+			buffer.WriteString(fmt.Sprintf("rename from %s\nrename to %s\n", oldName, newName))
+		}
+		if binaryChanged {
+			buffer.WriteString(fmt.Sprintf("Binary files %s and %s differ\n", oldPath, newPath))
+			continue
+		}
+		if len(groups) == 0 {
+			continue
+		}
+		buffer.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", oldPath, newPath))
+		for _, g := range groups {
+			buffer.WriteString(g.String())
+		}
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,94 @@
+package review
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func patchFiles(t *testing.T) VersionedFiles {
+	files, err := NewVersionedFiles([]UploadedFile{
+		uploaded(fileName, revisions[0]),
+		uploaded(headerName, "int f();\n"),
+		uploaded("removed.txt", "removed\n"),
+	}, RevisionInfo{})
+	require.NoError(t, err)
+	renamed := uploaded(otherName, revisions[1])
+	renamed.OldName = fileName
+	require.NoError(t, files.AddRevision([]UploadedFile{
+		renamed,
+		uploaded(headerName, "int f();\n"),
+		uploaded("added.txt", "added\n"),
+	}, RevisionInfo{}))
+	return files
+}
+
+func TestPatch(t *testing.T) {
+	files := patchFiles(t)
+	patch, err := files.Patch(0, 1)
+	require.NoError(t, err)
+	content := string(patch)
+	assert.Contains(t, content, "diff --git a/main.cpp b/list.cpp\nrename from main.cpp\nrename to list.cpp\n"+
+		"--- a/main.cpp\n+++ b/list.cpp\n@@ -1,")
+	assert.Contains(t, content, "diff --git a/added.txt b/added.txt\nnew file mode 100644\n"+
+		"--- /dev/null\n+++ b/added.txt\n@@ -0,0 +1 @@\n+added\n")
+	assert.Contains(t, content, "diff --git a/removed.txt b/removed.txt\ndeleted file mode 100644\n"+
+		"--- a/removed.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-removed\n")
+	// Unchanged file is skipped
+	assert.NotContains(t, content, headerName)
+
+	patch, err = files.Patch(1, 1)
+	require.NoError(t, err)
+	assert.Empty(t, patch)
+}
+
+func TestNoNewlineMarker(t *testing.T) {
+	group := DiffGroup{
+		OldRange: diffRange{From: 0, To: 1},
+		NewRange: diffRange{From: 0, To: 1},
+		Lines: []DiffLine{
+			{Type: DeleteOperation, Old: &Line{Content: "a"}},
+			{Type: InsertOperation, New: &Line{Content: "b\n"}},
+		},
+	}
+	assert.Equal(t, "@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+b\n", group.String())
+}
+
+func TestPatchApplies(t *testing.T) {
+	git, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "revisor-patch")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files := patchFiles(t)
+	first, err := files.GetRevision(0)
+	require.NoError(t, err)
+	for _, fr := range first.Files {
+		file, err := files.Files[fr.File].GetRevision(fr.Revision)
+		require.NoError(t, err)
+		content := strings.Join(splitContent(file), "")
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, fr.Name), []byte(content), 0644))
+	}
+	patch, err := files.Patch(0, 1)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "review.patch"), patch, 0644))
+
+	cmd := exec.Command(git, "apply", "review.patch")
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+	applied, err := ioutil.ReadFile(filepath.Join(dir, otherName))
+	require.NoError(t, err)
+	assert.Equal(t, strings.TrimSpace(revisions[1]), strings.TrimSpace(string(applied)))
+	_, err = os.Stat(filepath.Join(dir, "removed.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
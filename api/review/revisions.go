@@ -5,9 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
-
-	"github.com/pmezard/go-difflib/difflib"
-	uuid "github.com/satori/go.uuid"
 )
 
 // DiffType represents type of operation for particular line in diff
@@ -43,82 +40,56 @@ func (file File) Content() string {
 	return buffer.String()
 }
 
-// VersionedFile represents file with all it's revisions
+// VersionedFile represents file with all it's revisions. Revisions are stored as deltas
+// relative to previous revision and are rebuilt on demand
 type VersionedFile struct {
-	Name      string
-	Revisions []File
+	Name   string
+	deltas []delta
 }
 
 // NewVersionedFile constructs versioned file from content of original file
 func NewVersionedFile(name string, content []string) VersionedFile {
-	startRevision := File{make([]Line, 0, len(content))}
-	for _, line := range content {
-		u := uuid.NewV4()
-		startRevision.Lines = append(startRevision.Lines, Line{Content: line, Revision: 0, ID: u.String()})
-	}
-	file := VersionedFile{
-		Name:      name,
-		Revisions: []File{startRevision},
+	return newVersionedFile(name, content, 0)
+}
+
+// newVersionedFile constructs versioned file, which lines are marked with specified revision
+func newVersionedFile(name string, content []string, revision int) VersionedFile {
+	return VersionedFile{
+		Name:   name,
+		deltas: []delta{newDelta(nil, content, revision)},
 	}
-	return file
 }
 
 // RevisionsCount returns count of revisions (includes original file)
 func (file VersionedFile) RevisionsCount() int {
-	return len(file.Revisions)
+	return len(file.deltas)
 }
 
 // GetRevision returns specified revision of file
 func (file *VersionedFile) GetRevision(revision int) (File, error) {
-	if revision >= len(file.Revisions) || revision < 0 {
+	if revision >= len(file.deltas) || revision < 0 {
 		return File{}, fmt.Errorf(
-			"Bad revision: expected from %d to %d, got %d", 0, len(file.Revisions)-1, revision)
+			"Bad revision: expected from %d to %d, got %d", 0, len(file.deltas)-1, revision)
+	}
+	var lines []Line
+	for _, d := range file.deltas[:revision+1] {
+		lines = d.apply(lines)
 	}
-	return file.Revisions[revision], nil
+	return File{Lines: lines}, nil
 }
 
 // AddRevision adds new revision to the versioned file
 func (file *VersionedFile) AddRevision(content []string) error {
-	revisionsCount := file.RevisionsCount()
-	lastRevision, _ := file.GetRevision(revisionsCount - 1)
-	lastRevisionContent := make([]string, 0, len(lastRevision.Lines))
-	for _, line := range lastRevision.Lines {
-		lastRevisionContent = append(lastRevisionContent, line.Content)
-	}
-	newRevision := File{
-		Lines: make([]Line, len(lastRevision.Lines)),
-	}
-	copy(newRevision.Lines, lastRevision.Lines)
-
-	m := difflib.NewMatcher(lastRevisionContent, content)
-	origNumChanges := 0
-	for _, g := range m.GetGroupedOpCodes(0) {
-		for _, c := range g {
-			if c.Tag == 'e' {
-				continue
-			}
-			if c.Tag == 'd' || c.Tag == 'r' {
-				newRevision.Lines = append(
-					newRevision.Lines[:c.I1+origNumChanges],
-					newRevision.Lines[c.I2+origNumChanges:]...,
-				)
-				origNumChanges -= (c.I2 - c.I1)
-			}
-			if c.Tag == 'i' || c.Tag == 'r' {
-				for j := c.J1; j < c.J2; j++ {
-					u := uuid.NewV4()
-					newRevision.Lines = append(
-						newRevision.Lines[:j],
-						append(
-							[]Line{{Content: content[j], Revision: revisionsCount, ID: u.String()}},
-							newRevision.Lines[j:]...)...,
-					)
-				}
-				origNumChanges += (c.J2 - c.J1)
-			}
-		}
+	return file.addRevision(content, file.RevisionsCount())
+}
+
+// addRevision adds new revision, new lines of which are marked with specified revision
+func (file *VersionedFile) addRevision(content []string, revision int) error {
+	lastRevision, err := file.GetRevision(file.RevisionsCount() - 1)
+	if err != nil {
+		return err
 	}
-	file.Revisions = append(file.Revisions, newRevision)
+	file.deltas = append(file.deltas, newDelta(lastRevision.Lines, content, revision))
 	return nil
 }
 
@@ -127,6 +98,10 @@ type DiffLine struct {
 	Type DiffType `json:"-"`
 	Old  *Line    `json:"old"`
 	New  *Line    `json:"new"`
+	// Changed parts of replaced line or line, which replaces another one
+	Spans []Span `json:"spans,omitempty"`
+	// Line was moved from or to another place of file
+	Moved bool `json:"moved,omitempty"`
 }
 
 // MarshalJSON with corrected diff byte
@@ -183,15 +158,23 @@ func (group DiffGroup) String() string {
 		formatRangeUnified(group.OldRange.From, group.OldRange.To),
 		formatRangeUnified(group.NewRange.From, group.NewRange.To)))
 	for _, line := range group.Lines {
-		if line.Type == NoOperation {
+		var content string
+		if line.Type == NoOperation && line.Old != nil {
 			buffer.WriteString(" ")
-			buffer.WriteString(line.Old.Content)
+			content = line.Old.Content
+		} else if line.Type == NoOperation {
+			buffer.WriteString(" ")
+			content = line.New.Content
 		} else if line.Type == DeleteOperation {
 			buffer.WriteString("-")
-			buffer.WriteString(line.Old.Content)
+			content = line.Old.Content
 		} else if line.Type == InsertOperation {
 			buffer.WriteString("+")
-			buffer.WriteString(line.New.Content)
+			content = line.New.Content
+		}
+		buffer.WriteString(content)
+		if !strings.HasSuffix(content, "\n") {
+			buffer.WriteString("\n\\ No newline at end of file\n")
 		}
 	}
 	return buffer.String()
@@ -199,12 +182,21 @@ func (group DiffGroup) String() string {
 
 // Diff represents diff between revisions
 type Diff struct {
-	FileName string      `json:"filename"`
-	Groups   []DiffGroup `json:"groups"`
+	FileName    string      `json:"filename"`
+	OldFileName string      `json:"old_filename,omitempty"`
+	Added       bool        `json:"added,omitempty"`
+	Removed     bool        `json:"removed,omitempty"`
+	Groups      []DiffGroup `json:"groups"`
+	Binary      *BinaryDiff `json:"binary,omitempty"`
 }
 
-// Diff returns diff between two revisions as string
+// Diff returns diff between two revisions
 func (file *VersionedFile) Diff(revision1, revision2 int) (Diff, error) {
+	return file.DiffWithOptions(revision1, revision2, DiffOptions{})
+}
+
+// DiffWithOptions returns diff between two revisions, which ignores differences specified by options
+func (file *VersionedFile) DiffWithOptions(revision1, revision2 int, options DiffOptions) (Diff, error) {
 	file1, err := file.GetRevision(revision1)
 	if err != nil {
 		return Diff{}, err
@@ -213,76 +205,191 @@ func (file *VersionedFile) Diff(revision1, revision2 int) (Diff, error) {
 	if err != nil {
 		return Diff{}, err
 	}
-	file1Content, file2Content :=
-		difflib.SplitLines(strings.TrimSpace(file1.Content())),
-		difflib.SplitLines(strings.TrimSpace(file2.Content()))
-	m := difflib.NewMatcher(file1Content, file2Content)
-	diff := Diff{
-		FileName: file.Name,
-	}
 	if revision1 == revision2 {
-		// Return content of file
-		diff.Groups = append(diff.Groups, DiffGroup{
+		return contentDiff(file.Name, file1), nil
+	}
+	return diffFiles(file.Name, file1, file2, options), nil
+}
+
+// splitContent returns content of lines of file, which are equal to lines of uploaded file
+func splitContent(file File) []string {
+	result := make([]string, 0, len(file.Lines))
+	for _, line := range file.Lines {
+		result = append(result, line.Content)
+	}
+	return result
+}
+
+// contentDiff returns diff, which contains the whole content of file without changes
+func contentDiff(name string, file File) Diff {
+	content := splitContent(file)
+	diff := Diff{
+		FileName: name,
+		Groups: []DiffGroup{{
 			OldRange: diffRange{
 				From: 0,
-				To:   len(file1Content),
+				To:   len(content),
 			},
 			NewRange: diffRange{
 				From: 0,
-				To:   len(file1Content),
+				To:   len(content),
 			},
+		}},
+	}
+	for i := range content {
+		diff.Groups[0].Lines = append(diff.Groups[0].Lines, DiffLine{
+			Type: NoOperation,
+			Old:  &file.Lines[i],
+			New:  &file.Lines[i],
 		})
-		for i := range file1Content {
-			diff.Groups[0].Lines = append(diff.Groups[0].Lines, DiffLine{
-				Type: NoOperation,
-				Old:  &file1.Lines[i],
-				New:  &file2.Lines[i],
-			})
-		}
-		return diff, nil
 	}
-	for _, g := range m.GetGroupedOpCodes(10000) {
-		first, last := g[0], g[len(g)-1]
-		group := DiffGroup{
-			OldRange: diffRange{
-				From: first.I1,
-				To:   last.I2,
-			},
-			NewRange: diffRange{
-				From: first.J1,
-				To:   last.J2,
-			},
+	return diff
+}
+
+// diffBuilder collects lines of diff between two files
+type diffBuilder struct {
+	file1, file2       File
+	content1, content2 []string
+	lines              []DiffLine
+	positions          []linePosition
+	// Positions in files, up to which lines are added to diff
+	i, j int
+}
+
+// linePosition contains positions of line of diff in both files.
+// If line is absent in one of files, position of the next line of this file is used
+type linePosition struct {
+	Old int
+	New int
+}
+
+func (b *diffBuilder) add(line DiffLine, i, j int) {
+	b.lines = append(b.lines, line)
+	b.positions = append(b.positions, linePosition{Old: i, New: j})
+}
+
+// position of line of diff. Position of the end of files is returned for the line after the last one
+func (b *diffBuilder) position(line int) linePosition {
+	if line < len(b.positions) {
+		return b.positions[line]
+	}
+	return linePosition{Old: len(b.content1), New: len(b.content2)}
+}
+
+// skipped adds lines, which were ignored in comparison, up to specified positions.
+// Such lines are unchanged, but may be present only in one of files
+func (b *diffBuilder) skipped(i, j int) {
+	for b.i < i || b.j < j {
+		line := DiffLine{Type: NoOperation}
+		oldPos, newPos := b.i, b.j
+		if b.i < i {
+			line.Old = &b.file1.Lines[b.i]
+			b.i++
 		}
-		for _, c := range g {
-			if c.Tag == 'e' {
-				for i := 0; i < c.I2-c.I1; i++ {
-					group.Lines = append(group.Lines, DiffLine{
-						Type: NoOperation,
-						Old:  &file1.Lines[c.I1+i],
-						New:  &file2.Lines[c.J1+i],
-					})
-				}
-			}
-			if c.Tag == 'd' || c.Tag == 'r' {
-				for i := 0; i < c.I2-c.I1; i++ {
-					group.Lines = append(group.Lines, DiffLine{
-						Type: DeleteOperation,
-						Old:  &file1.Lines[c.I1+i],
-					})
-				}
-			}
-			if c.Tag == 'i' || c.Tag == 'r' {
-				for j := 0; j < c.J2-c.J1; j++ {
-					group.Lines = append(group.Lines, DiffLine{
-						Type: InsertOperation,
-						New:  &file2.Lines[c.J1+j],
-					})
-				}
+		if b.j < j {
+			line.New = &b.file2.Lines[b.j]
+			b.j++
+		}
+		b.add(line, oldPos, newPos)
+	}
+}
+
+// equal adds pair of equal lines
+func (b *diffBuilder) equal(i, j int) {
+	b.skipped(i, j)
+	b.add(DiffLine{
+		Type: NoOperation,
+		Old:  &b.file1.Lines[i],
+		New:  &b.file2.Lines[j],
+	}, i, j)
+	b.i, b.j = i+1, j+1
+}
+
+// changed adds specified lines of the first file as deleted and lines of the second one as inserted.
+// Lines between them, which were ignored in comparison, are added as unchanged
+func (b *diffBuilder) changed(old, new []int) {
+	i, j := b.i, b.j
+	if len(old) > 0 {
+		i = old[0]
+	}
+	if len(new) > 0 {
+		j = new[0]
+	}
+	b.skipped(i, j)
+	// Replaced lines are paired in order to highlight changed parts of them
+	oldSpans, newSpans := make([][]Span, len(old)), make([][]Span, len(new))
+	for k := 0; k < len(old) && k < len(new); k++ {
+		oldSpans[k], newSpans[k] = changedSpans(b.content1[old[k]], b.content2[new[k]])
+	}
+	for k, line := range old {
+		b.skipped(line, b.j)
+		b.add(DiffLine{
+			Type:  DeleteOperation,
+			Old:   &b.file1.Lines[line],
+			Spans: oldSpans[k],
+		}, line, b.j)
+		b.i = line + 1
+	}
+	for k, line := range new {
+		b.skipped(b.i, line)
+		b.add(DiffLine{
+			Type:  InsertOperation,
+			New:   &b.file2.Lines[line],
+			Spans: newSpans[k],
+		}, b.i, line)
+		b.j = line + 1
+	}
+}
+
+// buildDiff returns all lines of diff between two arbitrary files
+func buildDiff(file1, file2 File, options DiffOptions) *diffBuilder {
+	b := &diffBuilder{
+		file1:    file1,
+		file2:    file2,
+		content1: splitContent(file1),
+		content2: splitContent(file2),
+	}
+	compared1, compared2 := options.compared(b.content1), options.compared(b.content2)
+	for _, c := range options.algorithm().OpCodes(compared1.Content, compared2.Content) {
+		if c.Tag == 'e' {
+			for k := 0; k < c.I2-c.I1; k++ {
+				b.equal(compared1.Positions[c.I1+k], compared2.Positions[c.J1+k])
 			}
+			continue
+		}
+		b.changed(compared1.Positions[c.I1:c.I2], compared2.Positions[c.J1:c.J2])
+	}
+	b.skipped(len(b.content1), len(b.content2))
+	markMoved(b.lines)
+	return b
+}
+
+// group of lines of diff [from, to)
+func (b *diffBuilder) group(from, to int) DiffGroup {
+	first, last := b.position(from), b.position(to)
+	return DiffGroup{
+		OldRange: diffRange{From: first.Old, To: last.Old},
+		NewRange: diffRange{From: first.New, To: last.New},
+		Lines:    b.lines[from:to],
+	}
+}
+
+// diffFiles returns diff between two arbitrary files
+func diffFiles(name string, file1, file2 File, options DiffOptions) Diff {
+	b := buildDiff(file1, file2, options)
+	diff := Diff{
+		FileName: name,
+	}
+	if !options.Collapse {
+		if len(b.lines) > 0 {
+			diff.Groups = []DiffGroup{b.group(0, len(b.lines))}
 		}
-		diff.Groups = append(diff.Groups, group)
+		return diff
 	}
-	return diff, nil
+	for _, h := range b.hunks(options.Context) {
+		diff.Groups = append(diff.Groups, b.group(h.From, h.To))
+	}
+	return diff
 }
 
 func (diff Diff) String() string {
@@ -290,7 +397,11 @@ func (diff Diff) String() string {
 		return ""
 	}
 	var buffer bytes.Buffer
-	buffer.WriteString(fmt.Sprintf("--- %s\n", diff.FileName))
+	oldFileName := diff.FileName
+	if len(diff.OldFileName) > 0 {
+		oldFileName = diff.OldFileName
+	}
+	buffer.WriteString(fmt.Sprintf("--- %s\n", oldFileName))
 	buffer.WriteString(fmt.Sprintf("+++ %s\n", diff.FileName))
 	for _, g := range diff.Groups {
 		buffer.WriteString(g.String())
@@ -7,7 +7,6 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/pmezard/go-difflib/difflib"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/xerrors"
@@ -30,18 +29,18 @@ var (
 )
 
 func TestRevisionsCount(t *testing.T) {
-	file := NewVersionedFile(fileName, difflib.SplitLines(revisions[0]))
+	file := NewVersionedFile(fileName, splitLines(revisions[0]))
 	assert.Equal(t, file.RevisionsCount(), 1)
-	err := file.AddRevision(difflib.SplitLines(revisions[1]))
+	err := file.AddRevision(splitLines(revisions[1]))
 	assert.Nil(t, err)
 	assert.Equal(t, file.RevisionsCount(), 2)
-	err = file.AddRevision(difflib.SplitLines(revisions[2]))
+	err = file.AddRevision(splitLines(revisions[2]))
 	assert.Nil(t, err)
 	assert.Equal(t, file.RevisionsCount(), 3)
 }
 
 func TestRevisionContent(t *testing.T) {
-	file := NewVersionedFile(fileName, difflib.SplitLines(revisions[0]))
+	file := NewVersionedFile(fileName, splitLines(revisions[0]))
 	checkRevisionsContent := func() {
 		for i := 0; i < file.RevisionsCount(); i++ {
 			content, err := file.GetRevision(i)
@@ -52,16 +51,16 @@ func TestRevisionContent(t *testing.T) {
 		}
 	}
 	checkRevisionsContent()
-	err := file.AddRevision(difflib.SplitLines(revisions[1]))
+	err := file.AddRevision(splitLines(revisions[1]))
 	require.NoError(t, err)
 	checkRevisionsContent()
-	err = file.AddRevision(difflib.SplitLines(revisions[2]))
+	err = file.AddRevision(splitLines(revisions[2]))
 	require.NoError(t, err)
 	checkRevisionsContent()
 }
 
 func TestIncorrectRevisionNumber(t *testing.T) {
-	file := NewVersionedFile(fileName, difflib.SplitLines(revisions[0]))
+	file := NewVersionedFile(fileName, splitLines(revisions[0]))
 	_, err := file.GetRevision(0)
 	assert.NoError(t, err)
 	_, err = file.GetRevision(1)
@@ -72,11 +71,11 @@ func TestIncorrectRevisionNumber(t *testing.T) {
 
 func TestLineRevisionNumbers(t *testing.T) {
 	lineRevisions := [][]int{
-		{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
-		{0, 1, 0, 0, 0, 1, 1, 1, 1, 0, 1, 0, 0, 0, 0, 0, 0, 0},
-		{0, 0, 0, 0, 2, 2, 1, 2, 2, 2, 0, 2, 0, 0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		{0, 1, 0, 0, 0, 1, 1, 1, 1, 0, 1, 0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 2, 2, 1, 2, 2, 2, 0, 2, 0, 0, 0, 0, 0, 0},
 	}
-	file := NewVersionedFile(fileName, difflib.SplitLines(revisions[0]))
+	file := NewVersionedFile(fileName, splitLines(revisions[0]))
 	checkLineRevisions := func() {
 		rev, err := file.GetRevision(file.RevisionsCount() - 1)
 		assert.NoError(t, err)
@@ -89,10 +88,10 @@ func TestLineRevisionNumbers(t *testing.T) {
 		}
 	}
 	checkLineRevisions()
-	err := file.AddRevision(difflib.SplitLines(revisions[1]))
+	err := file.AddRevision(splitLines(revisions[1]))
 	require.NoError(t, err)
 	checkLineRevisions()
-	err = file.AddRevision(difflib.SplitLines(revisions[2]))
+	err = file.AddRevision(splitLines(revisions[2]))
 	require.NoError(t, err)
 	checkLineRevisions()
 }
@@ -115,7 +114,7 @@ func TestDiff(t *testing.T) {
 			2: readFile("third-third.diff"),
 		},
 	}
-	file := NewVersionedFile(fileName, difflib.SplitLines(revisions[0]))
+	file := NewVersionedFile(fileName, splitLines(revisions[0]))
 	checkDiffs := func() {
 		for i := 0; i < file.RevisionsCount(); i++ {
 			for j := 0; j < file.RevisionsCount(); j++ {
@@ -127,10 +126,10 @@ func TestDiff(t *testing.T) {
 			}
 		}
 	}
-	err := file.AddRevision(difflib.SplitLines(revisions[1]))
+	err := file.AddRevision(splitLines(revisions[1]))
 	require.NoError(t, err)
 	checkDiffs()
-	err = file.AddRevision(difflib.SplitLines(revisions[2]))
+	err = file.AddRevision(splitLines(revisions[2]))
 	require.NoError(t, err)
 	checkDiffs()
 }
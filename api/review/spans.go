@@ -0,0 +1,98 @@
+package review
+
+import (
+	"unicode"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+const (
+	// maxSpansLineLength limits length of lines, for which changed spans are calculated
+	maxSpansLineLength = 1000
+	// minSpansRatio is a minimal similarity of lines, for which changed spans make sense.
+	// Spans of completely different lines only add noise
+	minSpansRatio = 0.3
+)
+
+// Span represents changed part of line. Positions are in runes, To is not included
+type Span struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// token is a word, a run of spaces or a single punctuation symbol of line
+type token struct {
+	Text string
+	From int
+	To   int
+}
+
+func tokenClass(r rune) int {
+	if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+		return 1
+	}
+	if unicode.IsSpace(r) {
+		return 2
+	}
+	return 0
+}
+
+// tokenize splits line to words, runs of spaces and punctuation symbols
+func tokenize(line []rune) []token {
+	tokens := make([]token, 0)
+	for i := 0; i < len(line); {
+		j := i + 1
+		if class := tokenClass(line[i]); class != 0 {
+			for j < len(line) && tokenClass(line[j]) == class {
+				j++
+			}
+		}
+		tokens = append(tokens, token{Text: string(line[i:j]), From: i, To: j})
+		i = j
+	}
+	return tokens
+}
+
+func tokensText(tokens []token) []string {
+	result := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		result = append(result, t.Text)
+	}
+	return result
+}
+
+// appendSpan adds span of tokens [from, to) and merges it with the previous one if they are adjacent
+func appendSpan(spans []Span, tokens []token, from, to int) []Span {
+	if from == to {
+		return spans
+	}
+	span := Span{From: tokens[from].From, To: tokens[to-1].To}
+	if len(spans) > 0 && spans[len(spans)-1].To == span.From {
+		spans[len(spans)-1].To = span.To
+		return spans
+	}
+	return append(spans, span)
+}
+
+// changedSpans returns changed parts of replaced line and line, which replaces it.
+// Returns nil spans if lines are too long or too different
+func changedSpans(oldLine, newLine string) ([]Span, []Span) {
+	oldRunes, newRunes := []rune(oldLine), []rune(newLine)
+	if len(oldRunes) > maxSpansLineLength || len(newRunes) > maxSpansLineLength {
+		return nil, nil
+	}
+	oldTokens, newTokens := tokenize(oldRunes), tokenize(newRunes)
+	m := difflib.NewMatcher(tokensText(oldTokens), tokensText(newTokens))
+	if m.Ratio() < minSpansRatio {
+		return nil, nil
+	}
+	var oldSpans, newSpans []Span
+	for _, c := range m.GetOpCodes() {
+		if c.Tag == 'e' {
+			continue
+		}
+		oldSpans = appendSpan(oldSpans, oldTokens, c.I1, c.I2)
+		newSpans = appendSpan(newSpans, newTokens, c.J1, c.J2)
+	}
+	return oldSpans, newSpans
+}
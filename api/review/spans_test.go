@@ -0,0 +1,51 @@
+package review
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenize(t *testing.T) {
+	tokens := tokenize([]rune("if (x_1 >= 10)  {"))
+	assert.Equal(t, []string{"if", " ", "(", "x_1", " ", ">", "=", " ", "10", ")", "  ", "{"}, tokensText(tokens))
+	assert.Equal(t, token{Text: "x_1", From: 4, To: 7}, tokens[3])
+}
+
+func TestChangedSpans(t *testing.T) {
+	oldSpans, newSpans := changedSpans("int x = 10;\n", "int y = 10;\n")
+	assert.Equal(t, []Span{{From: 4, To: 5}}, oldSpans)
+	assert.Equal(t, []Span{{From: 4, To: 5}}, newSpans)
+
+	// Adjacent changed tokens are merged, positions are counted in runes
+	oldSpans, newSpans = changedSpans("строка = f(a, b)\n", "строка = g(c, b)\n")
+	assert.Equal(t, []Span{{From: 9, To: 10}, {From: 11, To: 12}}, oldSpans)
+	assert.Equal(t, []Span{{From: 9, To: 10}, {From: 11, To: 12}}, newSpans)
+
+	oldSpans, newSpans = changedSpans("return a;\n", "return a + b;\n")
+	assert.Empty(t, oldSpans)
+	assert.Equal(t, []Span{{From: 8, To: 12}}, newSpans)
+
+	// Completely different lines are not highlighted
+	oldSpans, newSpans = changedSpans("#include <vector>\n", "int main() {\n")
+	assert.Nil(t, oldSpans)
+	assert.Nil(t, newSpans)
+}
+
+func TestDiffSpans(t *testing.T) {
+	file := NewVersionedFile(fileName, splitLines("int a = 1;\nint b = 2;\n"))
+	require.NoError(t, file.AddRevision(splitLines("int a = 1;\nint b = 3;\nint c = 4;\n")))
+	diff, err := file.Diff(0, 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(diff.Groups))
+	lines := diff.Groups[0].Lines
+	require.Equal(t, 4, len(lines))
+	assert.Nil(t, lines[0].Spans)
+	assert.Equal(t, DeleteOperation, lines[1].Type)
+	assert.Equal(t, []Span{{From: 8, To: 9}}, lines[1].Spans)
+	assert.Equal(t, InsertOperation, lines[2].Type)
+	assert.Equal(t, []Span{{From: 8, To: 9}}, lines[2].Spans)
+	// Inserted line has no pair
+	assert.Nil(t, lines[3].Spans)
+}
@@ -0,0 +1,88 @@
+package review
+
+import (
+	"time"
+
+	"github.com/dbeliakov/revisor/api/store"
+	"golang.org/x/xerrors"
+)
+
+var (
+	// ErrIncorrectTransition error
+	ErrIncorrectTransition = xerrors.New("Incorrect transition of review state")
+
+	// transitions contains allowed transitions between states of review
+	transitions = map[store.ReviewState][]store.ReviewState{
+		store.StateOpen:             {store.StateChangesRequested, store.StateAccepted, store.StateDeclined},
+		store.StateReopened:         {store.StateChangesRequested, store.StateAccepted, store.StateDeclined},
+		store.StateChangesRequested: {store.StateOpen, store.StateAccepted, store.StateDeclined},
+		store.StateAccepted:         {store.StateReopened},
+		store.StateDeclined:         {store.StateReopened},
+	}
+)
+
+// reviewState returns state of review. Reviews, created before states were introduced, have no state
+func reviewState(review store.Review) store.ReviewState {
+	if len(review.State) > 0 {
+		return review.State
+	}
+	if review.Accepted {
+		return store.StateAccepted
+	}
+	if review.Closed {
+		return store.StateDeclined
+	}
+	return store.StateOpen
+}
+
+// isClosed checks if review in specified state is closed
+func isClosed(state store.ReviewState) bool {
+	return state == store.StateAccepted || state == store.StateDeclined
+}
+
+// changeState of review if transition is allowed and records it. Transition to the same state is ignored
+func changeState(review *store.Review, to store.ReviewState, actor string) error {
+	from := reviewState(*review)
+	if from == to {
+		return nil
+	}
+	allowed := false
+	for _, state := range transitions[from] {
+		if state == to {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return xerrors.Errorf("from %s to %s: %w", from, to, ErrIncorrectTransition)
+	}
+
+	review.State = to
+	review.Closed = isClosed(to)
+	review.Accepted = to == store.StateAccepted
+	review.Transitions = append(review.Transitions, store.Transition{
+		From:    from,
+		To:      to,
+		Actor:   actor,
+		Created: time.Now().Unix(),
+	})
+	return nil
+}
+
+// reopenReview changes state of closed review to reopened. Verdicts for the last revision are dropped,
+// because they closed review and reviewers have to decide again
+func reopenReview(review *store.Review, actor string) error {
+	err := changeState(review, store.StateReopened, actor)
+	if err != nil {
+		return err
+	}
+	revision := review.RevisionsCount - 1
+	verdicts := make([]store.Verdict, 0, len(review.Verdicts))
+	for _, verdict := range review.Verdicts {
+		if verdict.Revision != revision {
+			verdicts = append(verdicts, verdict)
+		}
+	}
+	review.Verdicts = verdicts
+	return nil
+}
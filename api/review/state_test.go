@@ -0,0 +1,77 @@
+package review
+
+import (
+	"testing"
+
+	"github.com/dbeliakov/revisor/api/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+)
+
+func TestLegacyReviewState(t *testing.T) {
+	assert.Equal(t, store.StateOpen, reviewState(store.Review{}))
+	assert.Equal(t, store.StateDeclined, reviewState(store.Review{Closed: true}))
+	assert.Equal(t, store.StateAccepted, reviewState(store.Review{Closed: true, Accepted: true}))
+	assert.Equal(t, store.StateReopened, reviewState(store.Review{State: store.StateReopened}))
+}
+
+func TestStateTransitions(t *testing.T) {
+	review := store.Review{State: store.StateOpen}
+	require.NoError(t, changeState(&review, store.StateChangesRequested, "reviewer"))
+	require.NoError(t, changeState(&review, store.StateOpen, "owner"))
+	require.NoError(t, changeState(&review, store.StateDeclined, "reviewer"))
+	assert.True(t, review.Closed)
+	assert.False(t, review.Accepted)
+
+	err := changeState(&review, store.StateAccepted, "reviewer")
+	assert.True(t, xerrors.Is(err, ErrIncorrectTransition))
+	err = changeState(&review, store.StateOpen, "owner")
+	assert.True(t, xerrors.Is(err, ErrIncorrectTransition))
+
+	require.NoError(t, changeState(&review, store.StateReopened, "owner"))
+	assert.False(t, review.Closed)
+	require.NoError(t, changeState(&review, store.StateAccepted, "reviewer"))
+	assert.True(t, review.Closed)
+	assert.True(t, review.Accepted)
+	// Transition to the same state is ignored
+	require.NoError(t, changeState(&review, store.StateAccepted, "reviewer"))
+
+	require.Equal(t, 5, len(review.Transitions))
+	last := review.Transitions[4]
+	assert.Equal(t, store.StateReopened, last.From)
+	assert.Equal(t, store.StateAccepted, last.To)
+	assert.Equal(t, "reviewer", last.Actor)
+	assert.NotZero(t, last.Created)
+}
+
+func TestVerdictsChangeState(t *testing.T) {
+	review := newPolicyReview(t, store.PolicyAll, 0)
+	review.State = store.StateOpen
+	require.NoError(t, setVerdict(&review, "first", store.VerdictChangesRequested, nil))
+	assert.Equal(t, store.StateChangesRequested, review.State)
+	require.NoError(t, setVerdict(&review, "first", store.VerdictApproved, nil))
+	assert.Equal(t, store.StateOpen, review.State)
+	require.NoError(t, setVerdict(&review, "second", store.VerdictApproved, nil))
+	require.NoError(t, setVerdict(&review, "third", store.VerdictApproved, nil))
+	assert.Equal(t, store.StateAccepted, review.State)
+
+	err := setVerdict(&review, "third", store.VerdictChangesRequested, nil)
+	assert.True(t, xerrors.Is(err, ErrIncorrectTransition))
+	assert.Equal(t, 3, len(review.Verdicts))
+}
+
+func TestReopenDropsVerdicts(t *testing.T) {
+	review := newPolicyReview(t, store.PolicyAny, 0)
+	review.State = store.StateOpen
+	require.NoError(t, setVerdict(&review, "first", store.VerdictApproved, nil))
+	require.True(t, review.Accepted)
+
+	require.NoError(t, reopenReview(&review, "owner"))
+	assert.Equal(t, store.StateReopened, review.State)
+	assert.Equal(t, 0, approvalsCount(review))
+	// Approval made before review was reopened does not accept it again
+	require.NoError(t, setVerdict(&review, "second", store.VerdictChangesRequested, nil))
+	assert.Equal(t, store.StateChangesRequested, review.State)
+	assert.False(t, review.Accepted)
+}
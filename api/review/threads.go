@@ -0,0 +1,34 @@
+package review
+
+import (
+	"github.com/dbeliakov/revisor/api/store"
+	"golang.org/x/xerrors"
+)
+
+var (
+	// ErrUnresolvedThreads error
+	ErrUnresolvedThreads = xerrors.New("Review has unresolved threads")
+)
+
+// unresolvedThreads counts root comments, which are not resolved. Threads with deleted root comment
+// are not counted
+func unresolvedThreads(comments []store.Comment) int {
+	count := 0
+	for _, comment := range comments {
+		if comment.ParentID == 0 && !comment.Resolved && !comment.Deleted {
+			count++
+		}
+	}
+	return count
+}
+
+// checkUnresolved threads before approval or acceptance of review, if they block it
+func checkUnresolved(review store.Review, comments []store.Comment) error {
+	if !review.BlockUnresolved {
+		return nil
+	}
+	if count := unresolvedThreads(comments); count > 0 {
+		return xerrors.Errorf("%d threads: %w", count, ErrUnresolvedThreads)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package review
+
+import (
+	"testing"
+
+	"github.com/dbeliakov/revisor/api/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+)
+
+func TestUnresolvedThreads(t *testing.T) {
+	comments := []store.Comment{
+		{ID: 1},
+		{ID: 2, ParentID: 1},
+		{ID: 3, Resolved: true, ResolvedBy: "reviewer", ResolvedAt: 1},
+		{ID: 4, ParentID: 3},
+		{ID: 5, Deleted: true},
+		{ID: 6, ParentID: 5},
+	}
+	assert.Equal(t, 1, unresolvedThreads(comments))
+
+	review := store.Review{}
+	assert.NoError(t, checkUnresolved(review, comments))
+	review.BlockUnresolved = true
+	err := checkUnresolved(review, comments)
+	assert.True(t, xerrors.Is(err, ErrUnresolvedThreads))
+	assert.NoError(t, checkUnresolved(review, comments[2:4]))
+}
+
+func TestUnresolvedThreadsBlockAcceptance(t *testing.T) {
+	comments := []store.Comment{{ID: 1}}
+	review := newPolicyReview(t, store.PolicyCount, 1)
+	review.State = store.StateOpen
+	review.BlockUnresolved = true
+
+	err := setVerdict(&review, "first", store.VerdictApproved, comments)
+	assert.True(t, xerrors.Is(err, ErrUnresolvedThreads))
+	assert.Empty(t, review.Verdicts)
+	require.NoError(t, setVerdict(&review, "first", store.VerdictChangesRequested, comments))
+	assert.Equal(t, store.StateChangesRequested, review.State)
+
+	// Approval, given before threads were opened, does not accept review by verdict of other reviewer
+	review.Verdicts[0].Status = store.VerdictApproved
+	err = setVerdict(&review, "second", store.VerdictChangesRequested, comments)
+	assert.True(t, xerrors.Is(err, ErrUnresolvedThreads))
+	assert.False(t, review.Accepted)
+
+	comments[0].Resolved = true
+	require.NoError(t, setVerdict(&review, "second", store.VerdictApproved, comments))
+	assert.True(t, review.Accepted)
+}
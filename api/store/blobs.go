@@ -0,0 +1,55 @@
+package store
+
+import (
+	"github.com/asdine/storm"
+	"golang.org/x/xerrors"
+)
+
+// blobsBucket contains raw content of binary files by their hashes
+const blobsBucket = "blobs"
+
+// Blob represents content of binary file. Blobs are identified by hash of content, so the same
+// content uploaded several times is stored once
+type Blob struct {
+	Hash    string
+	Content []byte
+}
+
+// BlobsStore provides access to content of binary files
+type BlobsStore interface {
+	SaveBlob(blob *Blob) error
+	FindBlobByHash(hash string) (Blob, error)
+}
+
+type blobsStoreImpl struct {
+	db *storm.DB
+}
+
+func newBlobsStore(db *storm.DB) BlobsStore {
+	return blobsStoreImpl{db: db}
+}
+
+// saveBlobs stores content as is, without encoding by codec of database
+func saveBlobs(tx storm.Node, blobs []Blob) error {
+	for _, blob := range blobs {
+		err := tx.SetBytes(blobsBucket, blob.Hash, blob.Content)
+		if err != nil {
+			return xerrors.Errorf("Cannot save blob: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s blobsStoreImpl) SaveBlob(blob *Blob) error {
+	return saveBlobs(s.db, []Blob{*blob})
+}
+
+func (s blobsStoreImpl) FindBlobByHash(hash string) (Blob, error) {
+	blob := Blob{Hash: hash}
+	content, err := s.db.GetBytes(blobsBucket, hash)
+	if err != nil {
+		return blob, xerrors.Errorf("Cannot find blob by hash: %w", err)
+	}
+	blob.Content = content
+	return blob, nil
+}
@@ -0,0 +1,31 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/asdine/storm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+)
+
+func TestBlobs(t *testing.T) {
+	initTestDatabase()
+	defer removeTestDatabase()
+
+	_, err := Blobs.FindBlobByHash("unknown")
+	assert.True(t, xerrors.Is(err, storm.ErrNotFound))
+
+	blob := Blob{Hash: "hash", Content: []byte{0, 1, 2}}
+	require.NoError(t, Blobs.SaveBlob(&blob))
+	// The same content can be saved again
+	require.NoError(t, Blobs.SaveBlob(&blob))
+	found, err := Blobs.FindBlobByHash("hash")
+	require.NoError(t, err)
+	assert.Equal(t, blob, found)
+
+	// Content is stored without encoding
+	raw, err := testDB.GetBytes(blobsBucket, "hash")
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 1, 2}, raw)
+}
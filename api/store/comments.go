@@ -7,6 +7,12 @@ import (
 	"golang.org/x/xerrors"
 )
 
+// CommentEdit represents previous version of text of edited comment
+type CommentEdit struct {
+	Text   string
+	Edited int64 // Time, when this version was replaced
+}
+
 // Comment represents information about comment
 type Comment struct {
 	ID       int `storm:"id,increment"`
@@ -14,7 +20,17 @@ type Comment struct {
 	Created  int64
 	Text     string
 	ParentID int
-	LineID   string
+	// Comment on the whole review has no line. Comment on range of lines has ID of the last line
+	LineID    string
+	EndLineID string
+	Updated   int64
+	Edits     []CommentEdit
+	// Deleted comment with replies is kept as tombstone without text
+	Deleted bool
+	// Resolution of thread, only root comments can be resolved
+	Resolved   bool
+	ResolvedBy string
+	ResolvedAt int64
 }
 
 // CommentsStore provides access to comments module storage
@@ -23,6 +39,9 @@ type CommentsStore interface {
 	FindCommentByID(reviewID, id int) (Comment, error)
 	CheckExists(reviewID, commentID int) (bool, error)
 	CommentsForReview(reviewID int) ([]Comment, error)
+	UpdateComment(reviewID int, comment *Comment) error
+	DeleteComment(reviewID int, comment *Comment) error
+	HasReplies(reviewID, commentID int) (bool, error)
 }
 
 type commentsStoreImpl struct {
@@ -74,3 +93,32 @@ func (s commentsStoreImpl) CommentsForReview(reviewID int) ([]Comment, error) {
 	}
 	return comments, nil
 }
+
+func (s commentsStoreImpl) UpdateComment(reviewID int, comment *Comment) error {
+	// Save instead of Update, because Update ignores zero values
+	err := s.node(reviewID).Save(comment)
+	if err != nil {
+		return xerrors.Errorf("Cannot update comment: %w", err)
+	}
+	return nil
+}
+
+func (s commentsStoreImpl) DeleteComment(reviewID int, comment *Comment) error {
+	err := s.node(reviewID).DeleteStruct(comment)
+	if err != nil {
+		return xerrors.Errorf("Cannot delete comment: %w", err)
+	}
+	return nil
+}
+
+func (s commentsStoreImpl) HasReplies(reviewID, id int) (bool, error) {
+	var reply Comment
+	err := s.node(reviewID).One("ParentID", id, &reply)
+	if err == storm.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, xerrors.Errorf("Cannot find replies to comment: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,42 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/asdine/storm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+)
+
+func TestUpdateAndDeleteComment(t *testing.T) {
+	initTestDatabase()
+	defer removeTestDatabase()
+
+	root := Comment{Author: user1.Login, Created: 1, Text: "First", LineID: "line"}
+	require.NoError(t, Comments.CreateComment(1, &root))
+	reply := Comment{Author: user2.Login, Created: 2, Text: "Reply", ParentID: root.ID, LineID: "line"}
+	require.NoError(t, Comments.CreateComment(1, &reply))
+
+	hasReplies, err := Comments.HasReplies(1, root.ID)
+	require.NoError(t, err)
+	assert.True(t, hasReplies)
+	hasReplies, err = Comments.HasReplies(1, reply.ID)
+	require.NoError(t, err)
+	assert.False(t, hasReplies)
+
+	root.Edits = append(root.Edits, CommentEdit{Text: root.Text, Edited: 3})
+	root.Text = ""
+	root.Deleted = true
+	require.NoError(t, Comments.UpdateComment(1, &root))
+	found, err := Comments.FindCommentByID(1, root.ID)
+	require.NoError(t, err)
+	assert.Equal(t, root, found)
+
+	require.NoError(t, Comments.DeleteComment(1, &reply))
+	_, err = Comments.FindCommentByID(1, reply.ID)
+	assert.True(t, xerrors.Is(err, storm.ErrNotFound))
+	hasReplies, err = Comments.HasReplies(1, root.ID)
+	require.NoError(t, err)
+	assert.False(t, hasReplies)
+}
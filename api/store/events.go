@@ -0,0 +1,85 @@
+package store
+
+import (
+	"strconv"
+
+	"github.com/asdine/storm"
+	"golang.org/x/xerrors"
+)
+
+// EventType represents type of action on review
+type EventType string
+
+const (
+	// EventCreated - review was created
+	EventCreated EventType = "created"
+	// EventRevisionUploaded - new revision was uploaded
+	EventRevisionUploaded EventType = "revision_uploaded"
+	// EventReviewerAdded - reviewer was added to review
+	EventReviewerAdded EventType = "reviewer_added"
+	// EventReviewerRemoved - reviewer was removed from review
+	EventReviewerRemoved EventType = "reviewer_removed"
+	// EventCommentPosted - comment was posted
+	EventCommentPosted EventType = "comment_posted"
+	// EventCommentEdited - text of comment was changed by author
+	EventCommentEdited EventType = "comment_edited"
+	// EventCommentDeleted - comment was deleted by author
+	EventCommentDeleted EventType = "comment_deleted"
+	// EventThreadResolved - thread of comments was marked as resolved
+	EventThreadResolved EventType = "thread_resolved"
+	// EventThreadUnresolved - resolved thread of comments was opened again
+	EventThreadUnresolved EventType = "thread_unresolved"
+	// EventVerdict - reviewer approved revision or requested changes
+	EventVerdict EventType = "verdict"
+	// EventStateChanged - review was accepted, declined, reopened etc.
+	EventStateChanged EventType = "state_changed"
+)
+
+// Event represents action on review
+type Event struct {
+	ID      int `storm:"id,increment"`
+	Type    EventType
+	Actor   string
+	Created int64
+	// Fields below are filled depending on type of event
+	Revision  int
+	Reviewer  string
+	CommentID int
+	Verdict   VerdictStatus
+	State     ReviewState
+}
+
+// EventsStore provides access to append-only log of events of reviews
+type EventsStore interface {
+	AddEvent(reviewID int, event *Event) error
+	EventsForReview(reviewID int) ([]Event, error)
+}
+
+type eventsStoreImpl struct {
+	db *storm.DB
+}
+
+func newEventsStore(db *storm.DB) EventsStore {
+	return eventsStoreImpl{db: db}
+}
+
+func (s eventsStoreImpl) node(reviewID int) storm.Node {
+	return s.db.From(strconv.Itoa(reviewID))
+}
+
+func (s eventsStoreImpl) AddEvent(reviewID int, event *Event) error {
+	err := s.node(reviewID).Save(event)
+	if err != nil {
+		return xerrors.Errorf("Cannot save event: %w", err)
+	}
+	return nil
+}
+
+func (s eventsStoreImpl) EventsForReview(reviewID int) ([]Event, error) {
+	events := make([]Event, 0)
+	err := s.node(reviewID).All(&events)
+	if err != nil {
+		return nil, xerrors.Errorf("Cannot load all events for review: %w", err)
+	}
+	return events, nil
+}
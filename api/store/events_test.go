@@ -0,0 +1,32 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventsForReview(t *testing.T) {
+	initTestDatabase()
+	defer removeTestDatabase()
+
+	events, err := Events.EventsForReview(1)
+	require.NoError(t, err)
+	assert.NotNil(t, events)
+	assert.Empty(t, events)
+
+	created := Event{Type: EventCreated, Actor: user1.Login, Created: 1}
+	require.NoError(t, Events.AddEvent(1, &created))
+	uploaded := Event{Type: EventRevisionUploaded, Actor: user1.Login, Created: 2, Revision: 1}
+	require.NoError(t, Events.AddEvent(1, &uploaded))
+	other := Event{Type: EventCreated, Actor: user2.Login, Created: 3}
+	require.NoError(t, Events.AddEvent(2, &other))
+
+	events, err = Events.EventsForReview(1)
+	require.NoError(t, err)
+	assert.Equal(t, []Event{created, uploaded}, events)
+	events, err = Events.EventsForReview(2)
+	require.NoError(t, err)
+	assert.Equal(t, []Event{other}, events)
+}
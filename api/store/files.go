@@ -0,0 +1,103 @@
+package store
+
+import (
+	"github.com/asdine/storm"
+	"github.com/valyala/fastjson"
+	"golang.org/x/xerrors"
+)
+
+// VersionedFile represents serialized content of all files of review with all their revisions
+type VersionedFile struct {
+	ID      int `storm:"id,increment"`
+	Content []byte
+}
+
+// FilesStore provides access to versioned files storage
+type FilesStore interface {
+	CreateFile(file *VersionedFile) error
+	FindFileByID(id int) (VersionedFile, error)
+	UpdateFile(file *VersionedFile) error
+}
+
+type filesStoreImpl struct {
+	db *storm.DB
+}
+
+func newFilesStore(db *storm.DB) FilesStore {
+	return filesStoreImpl{db: db}
+}
+
+func (s filesStoreImpl) CreateFile(file *VersionedFile) error {
+	err := s.db.Save(file)
+	if err != nil {
+		return xerrors.Errorf("Cannot save versioned file: %w", err)
+	}
+	return nil
+}
+
+func (s filesStoreImpl) FindFileByID(id int) (VersionedFile, error) {
+	var file VersionedFile
+	err := s.db.One("ID", id, &file)
+	if err != nil {
+		return file, xerrors.Errorf("Cannot find versioned file by ID: %w", err)
+	}
+	return file, nil
+}
+
+func (s filesStoreImpl) UpdateFile(file *VersionedFile) error {
+	err := s.db.Update(file)
+	if err != nil {
+		return xerrors.Errorf("Cannot update versioned file: %w", err)
+	}
+	return nil
+}
+
+// migrateReviewFiles moves versioned files, stored inside of reviews, to the separate bucket
+func migrateReviewFiles(db *storm.DB) error {
+	var reviews []Review
+	err := db.All(&reviews)
+	if err != nil {
+		return xerrors.Errorf("Cannot load reviews: %w", err)
+	}
+	for _, review := range reviews {
+		if len(review.File) == 0 {
+			continue
+		}
+		err = migrateReviewFile(db, review)
+		if err != nil {
+			return xerrors.Errorf("Cannot migrate review %d: %w", review.ID, err)
+		}
+	}
+	return nil
+}
+
+func migrateReviewFile(db *storm.DB, review Review) error {
+	var p fastjson.Parser
+	v, err := p.ParseBytes(review.File)
+	if err != nil {
+		return xerrors.Errorf("Cannot parse versioned file: %w", err)
+	}
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return xerrors.Errorf("Cannot start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	file := VersionedFile{Content: review.File}
+	err = tx.Save(&file)
+	if err != nil {
+		return xerrors.Errorf("Cannot save versioned file: %w", err)
+	}
+	review.FileID = file.ID
+	review.RevisionsCount = len(v.GetArray("Revisions"))
+	review.File = nil
+	// Save instead of Update, because Update ignores zero values
+	err = tx.Save(&review)
+	if err != nil {
+		return xerrors.Errorf("Cannot save review: %w", err)
+	}
+	return tx.Commit()
+}
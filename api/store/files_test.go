@@ -0,0 +1,57 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateAndUpdateFile(t *testing.T) {
+	initTestDatabase()
+	defer removeTestDatabase()
+
+	file := VersionedFile{Content: []byte(`{"Files":[],"Revisions":[]}`)}
+	err := Files.CreateFile(&file)
+	require.NoError(t, err)
+	assert.NotZero(t, file.ID)
+
+	found, err := Files.FindFileByID(file.ID)
+	require.NoError(t, err)
+	assert.Equal(t, file, found)
+
+	file.Content = []byte(`{"Files":[],"Revisions":[{}]}`)
+	err = Files.UpdateFile(&file)
+	require.NoError(t, err)
+	found, err = Files.FindFileByID(file.ID)
+	require.NoError(t, err)
+	assert.Equal(t, file, found)
+
+	_, err = Files.FindFileByID(file.ID + 1)
+	assert.Error(t, err)
+}
+
+func TestMigrateReviewFiles(t *testing.T) {
+	initTestDatabase()
+	defer removeTestDatabase()
+
+	content := []byte(`{"Name":"main.cpp","Revisions":[{"Lines":[]},{"Lines":[]}]}`)
+	legacy := Review{File: content, Name: "legacy", Owner: user1.Login}
+	require.NoError(t, Reviews.CreateReview(&legacy))
+	migrated := Review{FileID: 100, RevisionsCount: 3, Name: "migrated", Owner: user1.Login}
+	require.NoError(t, Reviews.CreateReview(&migrated))
+
+	require.NoError(t, migrateReviewFiles(testDB))
+
+	review, err := Reviews.FindReviewByID(legacy.ID)
+	require.NoError(t, err)
+	assert.Empty(t, review.File)
+	assert.Equal(t, 2, review.RevisionsCount)
+	file, err := Files.FindFileByID(review.FileID)
+	require.NoError(t, err)
+	assert.Equal(t, content, file.Content)
+
+	review, err = Reviews.FindReviewByID(migrated.ID)
+	require.NoError(t, err)
+	assert.Equal(t, migrated, review)
+}
@@ -1,30 +1,184 @@
 package store
 
 import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/asdine/storm"
 	"golang.org/x/xerrors"
 )
 
 // Review represents information about review
 type Review struct {
-	ID       int    `storm:"id,increment"`
-	File     []byte // TODO store id of versioned file
-	Name     string
-	Updated  int64
+	ID             int    `storm:"id,increment"`
+	File           []byte // Deprecated: versioned files are stored separately, see FileID
+	FileID         int
+	RevisionsCount int
+	Name           string
+	Updated        int64
+	State          ReviewState
+	// Closed and Accepted are derived from State and are used for filtering
 	Closed   bool
 	Accepted bool
 	Owner    string `storm:"index"`
-	// TODO create custom index for reviewers
+	// Reviewers are indexed separately, see reviewerEntry
 	Reviewers []string
+	// Verdicts of reviewers for all revisions
+	Verdicts []Verdict
+	// ApprovalPolicy defines how many approvals are required to accept review
+	ApprovalPolicy    ApprovalPolicy
+	RequiredApprovals int
+	// Transitions between states of review
+	Transitions []Transition
+	// BlockUnresolved forbids approvals while there are unresolved threads of comments
+	BlockUnresolved bool
+}
+
+// ReviewState represents state of review
+type ReviewState string
+
+const (
+	// StateOpen - review is waiting for verdicts of reviewers
+	StateOpen ReviewState = "open"
+	// StateChangesRequested - reviewer requested changes, review is waiting for new revision
+	StateChangesRequested ReviewState = "changes_requested"
+	// StateAccepted - review is accepted and closed
+	StateAccepted ReviewState = "accepted"
+	// StateDeclined - review is declined and closed
+	StateDeclined ReviewState = "declined"
+	// StateReopened - closed review was opened again
+	StateReopened ReviewState = "reopened"
+)
+
+// Transition represents change of review state
+type Transition struct {
+	From    ReviewState
+	To      ReviewState
+	Actor   string
+	Created int64
+}
+
+// VerdictStatus represents decision of reviewer
+type VerdictStatus string
+
+const (
+	// VerdictPending - reviewer has not made decision yet
+	VerdictPending VerdictStatus = "pending"
+	// VerdictApproved - reviewer approved revision
+	VerdictApproved VerdictStatus = "approved"
+	// VerdictChangesRequested - reviewer requested changes in revision
+	VerdictChangesRequested VerdictStatus = "changes_requested"
+)
+
+// Verdict represents decision of reviewer about particular revision
+type Verdict struct {
+	Reviewer string
+	Revision int
+	Status   VerdictStatus
+	Created  int64
+}
+
+// ApprovalPolicy defines how many approvals are required to accept review
+type ApprovalPolicy string
+
+const (
+	// PolicyAll requires approvals of all reviewers
+	PolicyAll ApprovalPolicy = "all"
+	// PolicyAny requires approval of any one reviewer
+	PolicyAny ApprovalPolicy = "any"
+	// PolicyCount requires RequiredApprovals approvals
+	PolicyCount ApprovalPolicy = "count"
+)
+
+// reviewerEntry links reviewer with review and is used as an index for searching reviews by reviewer
+type reviewerEntry struct {
+	Key      string `storm:"id"`
+	Reviewer string `storm:"index"`
+	ReviewID int
+}
+
+func newReviewerEntry(reviewer string, reviewID int) reviewerEntry {
+	return reviewerEntry{
+		Key:      fmt.Sprintf("%s/%d", reviewer, reviewID),
+		Reviewer: reviewer,
+		ReviewID: reviewID,
+	}
 }
 
+const (
+	metaBucket              = "meta"
+	reviewersIndexVersion   = "reviewers_index_version"
+	currentReviewersVersion = 1
+)
+
+// ReviewStatus is used for filtering of reviews
+type ReviewStatus string
+
+const (
+	// AnyStatus matches all reviews
+	AnyStatus ReviewStatus = ""
+	// StatusOpen matches not closed reviews
+	StatusOpen ReviewStatus = "open"
+	// StatusClosed matches closed reviews, both accepted and declined
+	StatusClosed ReviewStatus = "closed"
+	// StatusAccepted matches accepted reviews
+	StatusAccepted ReviewStatus = "accepted"
+	// StatusDeclined matches closed and not accepted reviews
+	StatusDeclined ReviewStatus = "declined"
+)
+
+// ReviewsSort is a field used for sorting of reviews
+type ReviewsSort string
+
+const (
+	// SortByID sorts reviews by ID
+	SortByID ReviewsSort = "id"
+	// SortByUpdated sorts reviews by time of last update
+	SortByUpdated ReviewsSort = "updated"
+)
+
+// ReviewsQuery describes filtering, sorting and pagination of reviews list
+type ReviewsQuery struct {
+	Status ReviewStatus
+	// Name is a case insensitive substring of review name
+	Name         string
+	Owner        string
+	UpdatedSince int64
+	Sort         ReviewsSort
+	Descending   bool
+	// Cursor from previous page, empty for the first page
+	Cursor string
+	// Limit of reviews on page, zero means no limit
+	Limit int
+}
+
+// ReviewsPage represents part of reviews list
+type ReviewsPage struct {
+	Reviews []Review
+	// Total count of reviews matching query
+	Total int
+	// NextCursor is empty for the last page
+	NextCursor string
+}
+
+var (
+	// ErrIncorrectCursor returns in case of cursor cannot be decoded
+	ErrIncorrectCursor = xerrors.New("Incorrect cursor")
+	// ErrIncorrectQuery returns in case of unknown status or sort field
+	ErrIncorrectQuery = xerrors.New("Incorrect query")
+)
+
 // ReviewsStore provides access to comments module storage
 type ReviewsStore interface {
 	CreateReview(review *Review) error
+	CreateReviewWithFile(review *Review, file *VersionedFile, blobs []Blob) error
 	FindReviewByID(id int) (Review, error)
-	FindReviewsByOwner(owner string) ([]Review, error)
-	FindReviewsByReviewer(reviewer string) ([]Review, error)
+	FindReviewsByOwner(owner string, query ReviewsQuery) (ReviewsPage, error)
+	FindReviewsByReviewer(reviewer string, query ReviewsQuery) (ReviewsPage, error)
 	UpdateReview(review *Review) error
+	UpdateReviewWithFile(review *Review, file *VersionedFile, blobs []Blob) error
 }
 
 type reviewsStoreImpl struct {
@@ -35,20 +189,130 @@ func newReviewsStore(db *storm.DB) ReviewsStore {
 	return reviewsStoreImpl{db: db}
 }
 
-func (s reviewsStoreImpl) CreateReview(review *Review) error {
-	err := s.db.Save(review)
+// updateReviewersIndex replaces entries of review in reviewers index
+func updateReviewersIndex(tx storm.Node, reviewID int, oldReviewers, newReviewers []string) error {
+	for _, reviewer := range oldReviewers {
+		entry := newReviewerEntry(reviewer, reviewID)
+		err := tx.DeleteStruct(&entry)
+		if err != nil && err != storm.ErrNotFound {
+			return xerrors.Errorf("Cannot delete reviewer from index: %w", err)
+		}
+	}
+	for _, reviewer := range newReviewers {
+		entry := newReviewerEntry(reviewer, reviewID)
+		err := tx.Save(&entry)
+		if err != nil {
+			return xerrors.Errorf("Cannot add reviewer to index: %w", err)
+		}
+	}
+	return nil
+}
+
+func createReview(tx storm.Node, review *Review) error {
+	err := tx.Save(review)
 	if err != nil {
 		return xerrors.Errorf("Cannot save review: %w", err)
 	}
-	return nil
+	return updateReviewersIndex(tx, review.ID, nil, review.Reviewers)
 }
 
-func (s reviewsStoreImpl) UpdateReview(review *Review) error {
-	err := s.db.Update(review)
+func updateReview(tx storm.Node, review *Review) error {
+	var old Review
+	err := tx.One("ID", review.ID, &old)
+	if err != nil {
+		return xerrors.Errorf("Cannot load review: %w", err)
+	}
+	// Save instead of Update, because Update ignores zero values
+	err = tx.Save(review)
 	if err != nil {
 		return xerrors.Errorf("Cannot update review: %w", err)
 	}
-	return nil
+	return updateReviewersIndex(tx, review.ID, old.Reviewers, review.Reviewers)
+}
+
+func (s reviewsStoreImpl) CreateReview(review *Review) error {
+	tx, err := s.db.Begin(true)
+	if err != nil {
+		return xerrors.Errorf("Cannot start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	err = createReview(tx, review)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CreateReviewWithFile saves versioned file, content of its binary files and review, which refers
+// to it, in single transaction
+func (s reviewsStoreImpl) CreateReviewWithFile(review *Review, file *VersionedFile, blobs []Blob) error {
+	tx, err := s.db.Begin(true)
+	if err != nil {
+		return xerrors.Errorf("Cannot start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	err = saveBlobs(tx, blobs)
+	if err != nil {
+		return err
+	}
+	err = tx.Save(file)
+	if err != nil {
+		return xerrors.Errorf("Cannot save versioned file: %w", err)
+	}
+	review.FileID = file.ID
+	err = createReview(tx, review)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s reviewsStoreImpl) UpdateReview(review *Review) error {
+	tx, err := s.db.Begin(true)
+	if err != nil {
+		return xerrors.Errorf("Cannot start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	err = updateReview(tx, review)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateReviewWithFile updates versioned file and review, which refers to it, and saves content of
+// new binary files in single transaction
+func (s reviewsStoreImpl) UpdateReviewWithFile(review *Review, file *VersionedFile, blobs []Blob) error {
+	tx, err := s.db.Begin(true)
+	if err != nil {
+		return xerrors.Errorf("Cannot start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	err = saveBlobs(tx, blobs)
+	if err != nil {
+		return err
+	}
+	err = tx.Update(file)
+	if err != nil {
+		return xerrors.Errorf("Cannot update versioned file: %w", err)
+	}
+	err = updateReview(tx, review)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (s reviewsStoreImpl) FindReviewByID(id int) (Review, error) {
@@ -60,32 +324,202 @@ func (s reviewsStoreImpl) FindReviewByID(id int) (Review, error) {
 	return review, nil
 }
 
-func (s reviewsStoreImpl) FindReviewsByOwner(owner string) ([]Review, error) {
+func (s reviewsStoreImpl) FindReviewsByOwner(owner string, query ReviewsQuery) (ReviewsPage, error) {
 	reviews := make([]Review, 0)
 	err := s.db.Find("Owner", owner, &reviews)
-	if err == storm.ErrNotFound {
-		return reviews, nil
+	if err != nil && err != storm.ErrNotFound {
+		return ReviewsPage{}, xerrors.Errorf("Cannot find reviews by owner: %w", err)
 	}
+	page, err := findReviews(reviews, query)
 	if err != nil {
-		return reviews, xerrors.Errorf("Cannot find users by Owner: %w", err)
+		return page, xerrors.Errorf("Cannot find reviews by owner: %w", err)
 	}
-	return reviews, nil
+	return page, nil
 }
 
-func (s reviewsStoreImpl) FindReviewsByReviewer(reviewer string) ([]Review, error) {
-	reviews := make([]Review, 0)
-	err := s.db.Select().Each(new(Review), func(v interface{}) error {
-		review := v.(*Review)
-		for _, rv := range review.Reviewers {
-			if rv == reviewer {
-				reviews = append(reviews, *review)
-				break
+func (s reviewsStoreImpl) FindReviewsByReviewer(reviewer string, query ReviewsQuery) (ReviewsPage, error) {
+	entries := make([]reviewerEntry, 0)
+	err := s.db.Find("Reviewer", reviewer, &entries)
+	if err != nil && err != storm.ErrNotFound {
+		return ReviewsPage{}, xerrors.Errorf("Cannot find reviews in reviewers index: %w", err)
+	}
+	reviews := make([]Review, 0, len(entries))
+	for _, entry := range entries {
+		var review Review
+		err = s.db.One("ID", entry.ReviewID, &review)
+		if err != nil {
+			return ReviewsPage{}, xerrors.Errorf("Cannot load review %d: %w", entry.ReviewID, err)
+		}
+		reviews = append(reviews, review)
+	}
+	page, err := findReviews(reviews, query)
+	if err != nil {
+		return page, xerrors.Errorf("Cannot find reviews by reviewer: %w", err)
+	}
+	return page, nil
+}
+
+func sortValue(review Review, sort ReviewsSort) int64 {
+	if sort == SortByUpdated {
+		return review.Updated
+	}
+	return int64(review.ID)
+}
+
+// reviewsCursor points to the last review of previous page
+type reviewsCursor struct {
+	value int64
+	id    int
+}
+
+func newCursor(review Review, sort ReviewsSort) reviewsCursor {
+	return reviewsCursor{value: sortValue(review, sort), id: review.ID}
+}
+
+// less compares position of cursors in ascending order
+func (c reviewsCursor) less(other reviewsCursor) bool {
+	if c.value != other.value {
+		return c.value < other.value
+	}
+	return c.id < other.id
+}
+
+func encodeCursor(review Review, sort ReviewsSort) string {
+	c := newCursor(review, sort)
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", c.value, c.id)))
+}
+
+func decodeCursor(cursor string) (reviewsCursor, error) {
+	var c reviewsCursor
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return c, xerrors.Errorf("Cannot decode cursor: %w", ErrIncorrectCursor)
+	}
+	_, err = fmt.Sscanf(string(decoded), "%d:%d", &c.value, &c.id)
+	if err != nil {
+		return c, xerrors.Errorf("Cannot parse cursor: %w", ErrIncorrectCursor)
+	}
+	return c, nil
+}
+
+// matchReview checks if review satisfies filters of query
+func matchReview(review Review, query ReviewsQuery) bool {
+	switch query.Status {
+	case StatusOpen:
+		if review.Closed {
+			return false
+		}
+	case StatusClosed:
+		if !review.Closed {
+			return false
+		}
+	case StatusAccepted:
+		if !review.Accepted {
+			return false
+		}
+	case StatusDeclined:
+		if !review.Closed || review.Accepted {
+			return false
+		}
+	}
+	if len(query.Name) > 0 && !strings.Contains(strings.ToLower(review.Name), strings.ToLower(query.Name)) {
+		return false
+	}
+	if len(query.Owner) > 0 && review.Owner != query.Owner {
+		return false
+	}
+	return review.Updated >= query.UpdatedSince
+}
+
+// findReviews filters, sorts and paginates reviews, loaded using one of indexes
+func findReviews(reviews []Review, query ReviewsQuery) (ReviewsPage, error) {
+	page := ReviewsPage{Reviews: make([]Review, 0)}
+	switch query.Status {
+	case AnyStatus, StatusOpen, StatusClosed, StatusAccepted, StatusDeclined:
+	default:
+		return page, xerrors.Errorf("unknown status %s: %w", query.Status, ErrIncorrectQuery)
+	}
+	switch query.Sort {
+	case SortByID, SortByUpdated, "":
+	default:
+		return page, xerrors.Errorf("unknown sort field %s: %w", query.Sort, ErrIncorrectQuery)
+	}
+	var after *reviewsCursor
+	if len(query.Cursor) > 0 {
+		c, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return page, err
+		}
+		after = &c
+	}
+
+	matched := make([]Review, 0, len(reviews))
+	for _, review := range reviews {
+		if matchReview(review, query) {
+			matched = append(matched, review)
+		}
+	}
+	page.Total = len(matched)
+	sort.Slice(matched, func(i, j int) bool {
+		if query.Descending {
+			return newCursor(matched[j], query.Sort).less(newCursor(matched[i], query.Sort))
+		}
+		return newCursor(matched[i], query.Sort).less(newCursor(matched[j], query.Sort))
+	})
+
+	for _, review := range matched {
+		if after != nil {
+			c := newCursor(review, query.Sort)
+			if (!query.Descending && !after.less(c)) || (query.Descending && !c.less(*after)) {
+				continue
 			}
 		}
+		if query.Limit > 0 && len(page.Reviews) == query.Limit {
+			page.NextCursor = encodeCursor(page.Reviews[query.Limit-1], query.Sort)
+			break
+		}
+		page.Reviews = append(page.Reviews, review)
+	}
+	return page, nil
+}
+
+// rebuildReviewersIndex creates reviewers index for all reviews, if it was not created yet
+func rebuildReviewersIndex(db *storm.DB) error {
+	var version int
+	err := db.Get(metaBucket, reviewersIndexVersion, &version)
+	if err != nil && err != storm.ErrNotFound {
+		return xerrors.Errorf("Cannot load version of reviewers index: %w", err)
+	}
+	if version == currentReviewersVersion {
 		return nil
-	})
+	}
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return xerrors.Errorf("Cannot start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	err = tx.Select().Delete(new(reviewerEntry))
+	if err != nil && err != storm.ErrNotFound {
+		return xerrors.Errorf("Cannot drop reviewers index: %w", err)
+	}
+	var reviews []Review
+	err = tx.All(&reviews)
+	if err != nil {
+		return xerrors.Errorf("Cannot load reviews: %w", err)
+	}
+	for _, review := range reviews {
+		err = updateReviewersIndex(tx, review.ID, nil, review.Reviewers)
+		if err != nil {
+			return err
+		}
+	}
+	err = tx.Set(metaBucket, reviewersIndexVersion, currentReviewersVersion)
 	if err != nil {
-		return nil, xerrors.Errorf("Cannot find reviews by reviewer: %w", err)
+		return xerrors.Errorf("Cannot save version of reviewers index: %w", err)
 	}
-	return reviews, err
+	return tx.Commit()
 }
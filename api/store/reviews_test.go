@@ -0,0 +1,196 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/asdine/storm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+)
+
+func reviewIDs(page ReviewsPage) []int {
+	ids := make([]int, 0, len(page.Reviews))
+	for _, r := range page.Reviews {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func TestFindReviewsByReviewer(t *testing.T) {
+	initTestDatabase()
+	defer removeTestDatabase()
+
+	first := Review{Name: "first", Owner: user1.Login, Reviewers: []string{user2.Login, user3.Login}}
+	require.NoError(t, Reviews.CreateReview(&first))
+	second := Review{Name: "second", Owner: user1.Login, Reviewers: []string{user2.Login}}
+	require.NoError(t, Reviews.CreateReview(&second))
+
+	page, err := Reviews.FindReviewsByReviewer(user2.Login, ReviewsQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, []int{first.ID, second.ID}, reviewIDs(page))
+	page, err = Reviews.FindReviewsByReviewer(user3.Login, ReviewsQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, []int{first.ID}, reviewIDs(page))
+	page, err = Reviews.FindReviewsByReviewer(user1.Login, ReviewsQuery{})
+	require.NoError(t, err)
+	assert.NotNil(t, page.Reviews)
+	assert.Empty(t, page.Reviews)
+
+	first.Reviewers = []string{user3.Login, user1.Login}
+	require.NoError(t, Reviews.UpdateReview(&first))
+	page, err = Reviews.FindReviewsByReviewer(user2.Login, ReviewsQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, []int{second.ID}, reviewIDs(page))
+	page, err = Reviews.FindReviewsByReviewer(user1.Login, ReviewsQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, []int{first.ID}, reviewIDs(page))
+	assert.Equal(t, "first", page.Reviews[0].Name)
+}
+
+func TestRebuildReviewersIndex(t *testing.T) {
+	initTestDatabase()
+	defer removeTestDatabase()
+
+	// Reviews, saved without index
+	first := Review{Name: "first", Owner: user1.Login, Reviewers: []string{user2.Login}}
+	require.NoError(t, testDB.Save(&first))
+	second := Review{Name: "second", Owner: user2.Login, Reviewers: []string{user1.Login, user3.Login}}
+	require.NoError(t, testDB.Save(&second))
+	page, err := Reviews.FindReviewsByReviewer(user3.Login, ReviewsQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Reviews)
+
+	require.NoError(t, rebuildReviewersIndex(testDB))
+	page, err = Reviews.FindReviewsByReviewer(user3.Login, ReviewsQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, []int{second.ID}, reviewIDs(page))
+	page, err = Reviews.FindReviewsByReviewer(user2.Login, ReviewsQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, []int{first.ID}, reviewIDs(page))
+
+	// Index is rebuilt only once
+	third := Review{Name: "third", Owner: user1.Login, Reviewers: []string{user3.Login}}
+	require.NoError(t, testDB.Save(&third))
+	require.NoError(t, rebuildReviewersIndex(testDB))
+	page, err = Reviews.FindReviewsByReviewer(user3.Login, ReviewsQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, []int{second.ID}, reviewIDs(page))
+}
+
+func TestReviewsQuery(t *testing.T) {
+	initTestDatabase()
+	defer removeTestDatabase()
+
+	reviews := []Review{
+		{Name: "Linked list", Owner: user1.Login, Updated: 30, Reviewers: []string{user2.Login}},
+		{Name: "Hash table", Owner: user1.Login, Updated: 10, Closed: true, Reviewers: []string{user2.Login}},
+		{Name: "Sorted list", Owner: user1.Login, Updated: 20, Closed: true, Accepted: true, Reviewers: []string{user2.Login}},
+		{Name: "Binary tree", Owner: user3.Login, Updated: 20, Reviewers: []string{user2.Login}},
+		{Name: "Other review", Owner: user2.Login, Updated: 40, Reviewers: []string{user1.Login}},
+	}
+	for i := range reviews {
+		require.NoError(t, Reviews.CreateReview(&reviews[i]))
+	}
+	ids := func(indexes ...int) []int {
+		result := make([]int, 0, len(indexes))
+		for _, i := range indexes {
+			result = append(result, reviews[i].ID)
+		}
+		return result
+	}
+
+	page, err := Reviews.FindReviewsByOwner(user1.Login, ReviewsQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, ids(0, 1, 2), reviewIDs(page))
+	assert.Equal(t, 3, page.Total)
+	assert.Empty(t, page.NextCursor)
+
+	page, err = Reviews.FindReviewsByOwner(user1.Login, ReviewsQuery{Status: StatusOpen})
+	require.NoError(t, err)
+	assert.Equal(t, ids(0), reviewIDs(page))
+	page, err = Reviews.FindReviewsByOwner(user1.Login, ReviewsQuery{Status: StatusClosed})
+	require.NoError(t, err)
+	assert.Equal(t, ids(1, 2), reviewIDs(page))
+	page, err = Reviews.FindReviewsByOwner(user1.Login, ReviewsQuery{Status: StatusAccepted})
+	require.NoError(t, err)
+	assert.Equal(t, ids(2), reviewIDs(page))
+	page, err = Reviews.FindReviewsByOwner(user1.Login, ReviewsQuery{Status: StatusDeclined})
+	require.NoError(t, err)
+	assert.Equal(t, ids(1), reviewIDs(page))
+	page, err = Reviews.FindReviewsByOwner(user1.Login, ReviewsQuery{Name: "LIST"})
+	require.NoError(t, err)
+	assert.Equal(t, ids(0, 2), reviewIDs(page))
+	page, err = Reviews.FindReviewsByOwner(user1.Login, ReviewsQuery{UpdatedSince: 20})
+	require.NoError(t, err)
+	assert.Equal(t, ids(0, 2), reviewIDs(page))
+	page, err = Reviews.FindReviewsByReviewer(user2.Login, ReviewsQuery{Owner: user3.Login})
+	require.NoError(t, err)
+	assert.Equal(t, ids(3), reviewIDs(page))
+
+	// Pagination with sorting by update time
+	query := ReviewsQuery{Sort: SortByUpdated, Descending: true, Limit: 2}
+	page, err = Reviews.FindReviewsByReviewer(user2.Login, query)
+	require.NoError(t, err)
+	assert.Equal(t, ids(0, 3), reviewIDs(page))
+	assert.Equal(t, 4, page.Total)
+	require.NotEmpty(t, page.NextCursor)
+	query.Cursor = page.NextCursor
+	page, err = Reviews.FindReviewsByReviewer(user2.Login, query)
+	require.NoError(t, err)
+	assert.Equal(t, ids(2, 1), reviewIDs(page))
+	assert.Equal(t, 4, page.Total)
+	assert.Empty(t, page.NextCursor)
+
+	query = ReviewsQuery{Sort: SortByID, Limit: 1}
+	page, err = Reviews.FindReviewsByOwner(user1.Login, query)
+	require.NoError(t, err)
+	assert.Equal(t, ids(0), reviewIDs(page))
+	query.Cursor = page.NextCursor
+	page, err = Reviews.FindReviewsByOwner(user1.Login, query)
+	require.NoError(t, err)
+	assert.Equal(t, ids(1), reviewIDs(page))
+
+	_, err = Reviews.FindReviewsByOwner(user1.Login, ReviewsQuery{Cursor: "incorrect"})
+	assert.True(t, xerrors.Is(err, ErrIncorrectCursor))
+	_, err = Reviews.FindReviewsByOwner(user1.Login, ReviewsQuery{Status: "unknown"})
+	assert.True(t, xerrors.Is(err, ErrIncorrectQuery))
+}
+
+func TestReviewWithFile(t *testing.T) {
+	initTestDatabase()
+	defer removeTestDatabase()
+
+	review := Review{Name: "review", Owner: user1.Login, Reviewers: []string{user2.Login}}
+	file := VersionedFile{Content: []byte("first")}
+	require.NoError(t, Reviews.CreateReviewWithFile(&review, &file, nil))
+	assert.Equal(t, file.ID, review.FileID)
+	stored, err := Files.FindFileByID(review.FileID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("first"), stored.Content)
+
+	review.Name = "updated"
+	file.Content = []byte("second")
+	require.NoError(t, Reviews.UpdateReviewWithFile(&review, &file, nil))
+	stored, err = Files.FindFileByID(review.FileID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("second"), stored.Content)
+	found, err := Reviews.FindReviewByID(review.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "updated", found.Name)
+
+	// Nothing is saved, if review cannot be updated
+	missing := Review{ID: review.ID + 1, FileID: file.ID}
+	blobs := []Blob{{Hash: "hash", Content: []byte{0, 1, 2}}}
+	assert.Error(t, Reviews.UpdateReviewWithFile(&missing, &VersionedFile{ID: file.ID, Content: []byte("third")}, blobs))
+	stored, err = Files.FindFileByID(review.FileID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("second"), stored.Content)
+	_, err = Blobs.FindBlobByHash("hash")
+	assert.True(t, xerrors.Is(err, storm.ErrNotFound))
+
+	require.NoError(t, Reviews.UpdateReviewWithFile(&review, &file, blobs))
+	blob, err := Blobs.FindBlobByHash("hash")
+	require.NoError(t, err)
+	assert.Equal(t, blobs[0], blob)
+}
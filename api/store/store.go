@@ -13,6 +13,12 @@ var (
 	Comments CommentsStore
 	// Reviews module storage
 	Reviews ReviewsStore
+	// Files of reviews storage
+	Files FilesStore
+	// Events of reviews storage
+	Events EventsStore
+	// Blobs with content of binary files
+	Blobs BlobsStore
 )
 
 // InitStore and open database
@@ -21,7 +27,27 @@ func InitStore() {
 	if err != nil {
 		panic(xerrors.Errorf("Cannot open database: %w", err))
 	}
+	if err := InitStoreWithDB(db); err != nil {
+		panic(err)
+	}
+}
+
+// InitStoreWithDB initializes storages using opened database
+func InitStoreWithDB(db *storm.DB) error {
 	Auth = newAuthStore(db)
 	Comments = newCommentsStore(db)
 	Reviews = newReviewsStore(db)
+	Files = newFilesStore(db)
+	Events = newEventsStore(db)
+	Blobs = newBlobsStore(db)
+
+	err := migrateReviewFiles(db)
+	if err != nil {
+		return xerrors.Errorf("Cannot migrate files of reviews: %w", err)
+	}
+	err = rebuildReviewersIndex(db)
+	if err != nil {
+		return xerrors.Errorf("Cannot rebuild reviewers index: %w", err)
+	}
+	return nil
 }
@@ -11,6 +11,10 @@ const (
 	testDatabase = "test_database.db"
 )
 
+var (
+	testDB *storm.DB
+)
+
 func initTestDatabase() {
 	db, err := storm.Open(testDatabase)
 	if err != nil {
@@ -19,10 +23,18 @@ func initTestDatabase() {
 	Auth = newAuthStore(db)
 	Comments = newCommentsStore(db)
 	Reviews = newReviewsStore(db)
+	Files = newFilesStore(db)
+	Events = newEventsStore(db)
+	Blobs = newBlobsStore(db)
+	testDB = db
 }
 
 func removeTestDatabase() {
-	err := os.Remove(testDatabase)
+	err := testDB.Close()
+	if err != nil {
+		panic(xerrors.Errorf("Cannot close test database: %w", err))
+	}
+	err = os.Remove(testDatabase)
 	if err != nil {
 		panic(xerrors.Errorf("Cannot remove test database: %w", err))
 	}
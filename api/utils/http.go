@@ -3,7 +3,9 @@ package utils
 import (
 	"encoding/json"
 	"io/ioutil"
+	"mime"
 	"net/http"
+	"strconv"
 
 	"github.com/sirupsen/logrus"
 	"golang.org/x/xerrors"
@@ -20,6 +22,8 @@ type JSONErrorResponse struct {
 	Status        int    `json:"status"`
 	Message       string `json:"message"`
 	ClientMessage string `json:"client_message"`
+	// Details of error, which can be processed by client
+	Details interface{} `json:"details,omitempty"`
 }
 
 // InternalErrorResponse template with custom message
@@ -83,6 +87,16 @@ func Ok(w http.ResponseWriter, data interface{}) {
 	_, _ = w.Write(bytes)
 }
 
+// Attachment writes content of file, which should be downloaded with specified name
+func Attachment(w http.ResponseWriter, contentType string, filename string, content []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(content)
+}
+
 var (
 	// ErrIncorrectBody error
 	ErrIncorrectBody = xerrors.New("Incorrect body")
@@ -1,12 +1,32 @@
 import { UserInfo } from '@/auth/user-info';
 
+export class CommentContext {
+    public file: string;
+    public revision: number;
+    public lines: string[];
+    public line: number;
+
+    public constructor(json: any) {
+        this.file = json.file;
+        this.revision = json.revision;
+        this.lines = json.lines.map((line: any) => line.content);
+        this.line = json.line;
+    }
+}
+
 export default class Comment {
     public id: number;
     public author: UserInfo;
     public created: Date;
     public text: string;
     public lineId: string;
+    public endLineId: string;
     public childs: Comment[];
+    public edited: boolean;
+    public deleted: boolean;
+    public resolved: boolean;
+    public outdated: boolean;
+    public context: CommentContext | null;
 
     public constructor(json: any) {
         this.id = json.id;
@@ -14,6 +34,12 @@ export default class Comment {
         this.created = new Date(json.created * 1000);
         this.text = json.text;
         this.lineId = json.line_id;
+        this.endLineId = json.end_line_id || '';
+        this.edited = json.edits && json.edits.length > 0;
+        this.deleted = !!json.deleted;
+        this.resolved = !!json.resolved;
+        this.outdated = !!json.outdated;
+        this.context = json.context ? new CommentContext(json.context) : null;
         if (json.childs) {
             this.childs = json.childs.map((child: any) => new Comment(child));
         } else {
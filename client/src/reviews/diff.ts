@@ -53,12 +53,34 @@ export class DiffGroup {
     }
 }
 
+export class BinaryDiff {
+    public oldSize: number;
+    public newSize: number;
+    public changed: boolean;
+
+    public constructor(json: any) {
+        this.oldSize = json.old_size;
+        this.newSize = json.new_size;
+        this.changed = json.changed;
+    }
+}
+
 export class Diff {
     public filename: string;
+    public oldFilename: string;
+    public added: boolean;
+    public removed: boolean;
     public groups: DiffGroup[];
+    public binary: BinaryDiff | undefined;
 
     public constructor(json: any) {
         this.filename = json.filename;
+        this.oldFilename = json.old_filename || '';
+        this.added = !!json.added;
+        this.removed = !!json.removed;
+        if (json.binary) {
+            this.binary = new BinaryDiff(json.binary);
+        }
         this.groups = [];
         for (const group of json.groups) {
             this.groups.push(new DiffGroup(group));
@@ -1,19 +1,38 @@
 import { UserInfo } from '@/auth/user-info';
 
+export class Transition {
+    public from: string;
+    public to: string;
+    public actor: string;
+    public created: Date;
+
+    public constructor(json: any) {
+        this.from = json.from;
+        this.to = json.to;
+        this.actor = json.actor;
+        this.created = new Date(json.created * 1000);
+    }
+}
+
 export default class Review {
     public id: number;
     public name: string;
+    public state: string;
     public closed: boolean;
     public accepted: boolean;
     public owner: UserInfo;
     public reviewers: UserInfo[];
     public commentsCount: number;
     public revisionsCount: number;
+    public unresolvedCount: number;
+    public approvals: number;
+    public requiredApprovals: number;
     public updated: Date;
 
     public constructor(json: any) {
         this.id = json.id;
         this.name = json.name;
+        this.state = json.state;
         this.closed = json.closed;
         this.accepted = json.accepted;
         this.owner = new UserInfo(json.owner);
@@ -23,6 +42,9 @@ export default class Review {
         }
         this.commentsCount = json.comments_count;
         this.revisionsCount = json.revisions_count;
+        this.unresolvedCount = json.unresolved_count || 0;
+        this.approvals = json.approvals || 0;
+        this.requiredApprovals = json.required_approvals || 0;
         this.updated = new Date(json.updated * 1000);
     }
 }
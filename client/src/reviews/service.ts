@@ -1,18 +1,53 @@
 import { AxiosStatic } from '../../node_modules/axios';
-import Review from '@/reviews/review';
+import Review, { Transition } from '@/reviews/review';
 import { responseToError } from '@/utils/utils';
 import {UserInfo} from '@/auth/user-info';
 import { Diff } from '@/reviews/diff';
 import Comment from '@/reviews/comment';
 
+// UploadedFile is a file of review revision with base64 encoded content
+export interface UploadedFile {
+    name: string;
+    content: string;
+    oldName?: string;
+}
+
+function filesForm(files: UploadedFile[]) {
+    return files.map((file) => ({name: file.name, old_name: file.oldName || '', content: file.content}));
+}
+
+// ReviewsQuery filters and pages lists of reviews
+export interface ReviewsQuery {
+    status?: string;
+    name?: string;
+    cursor?: string;
+    limit?: number;
+}
+
+export class ReviewsPage {
+    public reviews: Review[];
+    public total: number;
+    // Empty for the last page
+    public nextCursor: string;
+
+    public constructor(json: any) {
+        this.reviews = json.reviews.map((review: any) => new Review(review));
+        this.total = json.total;
+        this.nextCursor = json.next_cursor || '';
+    }
+}
+
 export class DiffReply {
     public info: Review;
-    public diff: Diff;
+    // Diff of each file of review
+    public diff: Diff[];
     public comments: Comment[];
+    public transitions: Transition[];
 
     public constructor(json: any) {
         this.info = new Review(json.info);
-        this.diff = new Diff(json.diff);
+        this.diff = json.diff.map((diff: any) => new Diff(diff));
+        this.transitions = (json.transitions || []).map((transition: any) => new Transition(transition));
         this.comments = [];
         for (const comment of json.comments) {
             this.comments.push(new Comment(comment));
@@ -27,25 +62,23 @@ export default class ReviewsService {
         this.axios = axios;
     }
 
-    public async loadIncomingReviews(): Promise<Review[] | Error> {
-        return this.loadReviews('/reviews/incoming');
+    public async loadIncomingReviews(query: ReviewsQuery = {}): Promise<ReviewsPage | Error> {
+        return this.loadReviews('/reviews/incoming', query);
     }
 
-    public async loadOutgoingReviews(): Promise<Review[] | Error> {
-        return this.loadReviews('/reviews/outgoing');
+    public async loadOutgoingReviews(query: ReviewsQuery = {}): Promise<ReviewsPage | Error> {
+        return this.loadReviews('/reviews/outgoing', query);
     }
 
     public async createReview(
             name: string,
             reviewers: string,
-            fileName: string,
-            fileContent: string): Promise<Error | undefined> {
+            files: UploadedFile[]): Promise<Error | undefined> {
         try {
             await this.axios.post('/reviews/new', {
                 name,
                 reviewers,
-                file_content: fileContent,
-                file_name: fileName,
+                files: filesForm(files),
             });
         } catch (error) {
             return responseToError(error);
@@ -56,12 +89,11 @@ export default class ReviewsService {
             reviewId: number,
             name: string,
             reviewers: string,
-            fileName: string | null,
-            fileContent: string | null): Promise<Error | undefined> {
+            files: UploadedFile[]): Promise<Error | undefined> {
         try {
-            const params: {[id: string]: string; } = {name, reviewers};
-            if (fileName !== null && fileContent !== null) {
-                params.new_revision = fileContent;
+            const params: {[id: string]: any; } = {name, reviewers};
+            if (files.length > 0) {
+                params.files = filesForm(files);
             }
             await this.axios.post('/reviews/' + reviewId + '/update', params);
         } catch (error) {
@@ -98,8 +130,8 @@ export default class ReviewsService {
         }
     }
 
-    public async addComment(lineId: string, reviewId: number, text: string, parentId: number = 0)
-            : Promise<Error | undefined> {
+    public async addComment(lineId: string, reviewId: number, text: string, parentId: number = 0,
+                            endLineId: string = ''): Promise<Error | undefined> {
         try {
             const data = {
                 review_id: Number(reviewId),
@@ -109,12 +141,40 @@ export default class ReviewsService {
             if (parentId > 0) {
                 (data as any).parent = parentId;
             }
+            if (endLineId) {
+                (data as any).end_line_id = endLineId;
+            }
             await this.axios.post('/comments/add', data);
         } catch (error) {
             return responseToError(error);
         }
     }
 
+    public async editComment(reviewId: number, commentId: number, text: string): Promise<Error | undefined> {
+        try {
+            await this.axios.post('/comments/edit', {review_id: Number(reviewId), comment_id: commentId, text});
+        } catch (error) {
+            return responseToError(error);
+        }
+    }
+
+    public async resolveComment(reviewId: number, commentId: number, resolved: boolean)
+            : Promise<Error | undefined> {
+        try {
+            await this.axios.post('/comments/resolve', {review_id: Number(reviewId), comment_id: commentId, resolved});
+        } catch (error) {
+            return responseToError(error);
+        }
+    }
+
+    public async deleteComment(reviewId: number, commentId: number): Promise<Error | undefined> {
+        try {
+            await this.axios.post('/comments/delete', {review_id: Number(reviewId), comment_id: commentId});
+        } catch (error) {
+            return responseToError(error);
+        }
+    }
+
     public async acceptReview(reviewId: number): Promise<Error | undefined> {
         try {
             await this.axios.post('/reviews/' + reviewId + '/accept');
@@ -131,14 +191,17 @@ export default class ReviewsService {
         }
     }
 
-    private async loadReviews(path: string): Promise<Review[] | Error> {
+    private async loadReviews(path: string, query: ReviewsQuery): Promise<ReviewsPage | Error> {
         try {
-            const response = await this.axios.get(path);
-            const result: Review[] = [];
-            for (const review of response.data.data) {
-                result.push(new Review(review));
+            const params: {[key: string]: string | number} = {};
+            for (const key of Object.keys(query)) {
+                const value = (query as any)[key];
+                if (value) {
+                    params[key] = value;
+                }
             }
-            return result;
+            const response = await this.axios.get(path, {params});
+            return new ReviewsPage(response.data.data);
         } catch (error) {
             return responseToError(error);
         }